@@ -0,0 +1,224 @@
+package interp
+
+// Узел синтаксического дерева
+type AstNode interface {
+	Pos() Span
+}
+
+// Выражение
+type Expr interface {
+	AstNode
+	exprNode()
+}
+
+// Инструкция
+type Stmt interface {
+	AstNode
+	stmtNode()
+	Number() int
+	HasBreakpoint() bool
+}
+
+// общая часть инструкций: диапазон, номер с 1 и отметка #BREAKPOINT
+type stmtBase struct {
+	Span       Span
+	Index      int
+	Breakpoint bool
+}
+
+func (s *stmtBase) Pos() Span           { return s.Span }
+func (s *stmtBase) Number() int         { return s.Index }
+func (s *stmtBase) HasBreakpoint() bool { return s.Breakpoint }
+func (s *stmtBase) stmtNode()           {}
+
+// Программа
+type Program struct {
+	File     string
+	Source   string
+	Stmts    []Stmt
+	Comments []Token
+
+	// заполняются Compile: диалект разбора и байт-код, nil - выполнение обходом дерева
+	dialect *Dialect
+	code    *Bytecode
+}
+
+// Присваивание; Name - переменная независимо от направления left=/right=
+type AssignStmt struct {
+	stmtBase
+	Name  *Ident
+	Value Expr
+}
+
+// Выражение как инструкция, например вывод
+type ExprStmt struct {
+	stmtBase
+	X Expr
+}
+
+// Пустая инструкция, нужна чтобы не терять #BREAKPOINT
+type EmptyStmt struct {
+	stmtBase
+}
+
+// Условная инструкция if <условие> { ... } else { ... }; Else может быть nil
+type IfStmt struct {
+	stmtBase
+	Cond Expr
+	Then *Block
+	Else *Block
+}
+
+// Цикл while <условие> { ... }
+type WhileStmt struct {
+	stmtBase
+	Cond Expr
+	Body *Block
+}
+
+// Объявление функции func имя(параметры) { ... }
+type FuncDecl struct {
+	stmtBase
+	Name   *Ident
+	Params []*Ident
+	Body   *Block
+}
+
+// Возврат значения из функции
+type ReturnStmt struct {
+	stmtBase
+	Value Expr
+}
+
+// Блок инструкций в фигурных скобках; Span - от { до }
+type Block struct {
+	Stmts []Stmt
+	Span  Span
+}
+
+// Числовой литерал
+type NumberLit struct {
+	Token
+}
+
+// Переменная
+type Ident struct {
+	Token
+}
+
+// Вызов input/output; ArgText - исходный текст аргумента для вывода
+type CallExpr struct {
+	Op      Token
+	Args    []Expr
+	ArgText string
+	Span    Span
+}
+
+// Вызов пользовательской функции в записи, заданной диалектом для её числа аргументов
+type FuncCall struct {
+	Name Token
+	Args []Expr
+	Span Span
+}
+
+// Унарная операция
+type UnaryExpr struct {
+	Op   Token
+	X    Expr
+	Span Span
+}
+
+// Бинарная операция в любой из форм op(), ()op, (op)
+type BinaryExpr struct {
+	Op   Token
+	X, Y Expr
+	Span Span
+}
+
+func (e *NumberLit) Pos() Span  { return e.Token.Span }
+func (e *Ident) Pos() Span      { return e.Token.Span }
+func (e *CallExpr) Pos() Span   { return e.Span }
+func (e *UnaryExpr) Pos() Span  { return e.Span }
+func (e *BinaryExpr) Pos() Span { return e.Span }
+func (e *FuncCall) Pos() Span   { return e.Span }
+
+func (*NumberLit) exprNode()  {}
+func (*Ident) exprNode()      {}
+func (*CallExpr) exprNode()   {}
+func (*UnaryExpr) exprNode()  {}
+func (*BinaryExpr) exprNode() {}
+func (*FuncCall) exprNode()   {}
+
+// объединение диапазонов
+func joinSpan(a, b Span) Span {
+	return Span{File: a.File, Start: a.Start, End: b.End}
+}
+
+// Заголовок инструкции: у if, while и func - от ключевого слова до {, у остальных - вся инструкция
+func stmtHeader(stmt Stmt) Span {
+	span := stmt.Pos()
+	switch s := stmt.(type) {
+	case *IfStmt:
+		span.End = s.Then.Span.Start
+	case *WhileStmt:
+		span.End = s.Body.Span.Start
+	case *FuncDecl:
+		span.End = s.Body.Span.Start
+	}
+	return span
+}
+
+// Обход инструкций в порядке исходного текста, включая вложенные в блоки
+func walkStmts(stmts []Stmt, visit func(Stmt)) {
+	for _, stmt := range stmts {
+		visit(stmt)
+		switch s := stmt.(type) {
+		case *IfStmt:
+			walkStmts(s.Then.Stmts, visit)
+			if s.Else != nil {
+				walkStmts(s.Else.Stmts, visit)
+			}
+		case *WhileStmt:
+			walkStmts(s.Body.Stmts, visit)
+		case *FuncDecl:
+			walkStmts(s.Body.Stmts, visit)
+		}
+	}
+}
+
+// Выражение самой инструкции без вложенных блоков; nil - у инструкции его нет
+func stmtExpr(stmt Stmt) Expr {
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		return s.Value
+	case *ExprStmt:
+		return s.X
+	case *IfStmt:
+		return s.Cond
+	case *WhileStmt:
+		return s.Cond
+	case *ReturnStmt:
+		return s.Value
+	}
+	return nil
+}
+
+// Обход выражения и всех его подвыражений
+func walkExpr(expr Expr, visit func(Expr)) {
+	visit(expr)
+	switch e := expr.(type) {
+	case *CallExpr:
+		for _, arg := range e.Args {
+			walkExpr(arg, visit)
+		}
+	case *FuncCall:
+		for _, arg := range e.Args {
+			walkExpr(arg, visit)
+		}
+	case *UnaryExpr:
+		walkExpr(e.X, visit)
+	case *BinaryExpr:
+		walkExpr(e.X, visit)
+		walkExpr(e.Y, visit)
+	}
+}
@@ -1,12 +1,12 @@
-package main
+package interp
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
-	"math"
-	//"io"
-	"bufio"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -21,48 +21,88 @@ type Interpreter struct {
 	Result 		   string
 	UnarySyntax    string
 	BinarySyntax   string
+	Precedence     map[string]int
+	RightAssoc     map[string]bool
 	Debug          bool
+	Debugger       Debugger
+	Breakpoints    []*Breakpoint
+	Stepping       bool
+	Watches        []*Watch
+	VarHistory     *Trie // история изменений переменных: []VarWrite
+	Numeric        NumericBackend
 	SettingsFile   string
+	ProgramFile    string
+	KeepGoing      bool
 	Oper           []string
+	In             io.Reader // ввод для input
+	Out            io.Writer // вывод output
+	Prompt         io.Writer // приглашения перед вводом; nil - без приглашений
+	Functions      map[string]*FuncDecl
+	MaxCallDepth   int  // 0 - defaultMaxCallDepth
+	TreeWalk       bool // выполнять обходом дерева, а не байт-кодом
+	Optimize       bool // оптимизировать программу перед выполнением вне отладчика
+	Limits         Limits
+	frames         []*Frame
+	stepDepth      int  // шаг останавливается на глубине вызовов не больше этой
+	current        Stmt // выполняемая инструкция
+	ctx            context.Context // отмена выполнения; nil - без отмены
+	stopErr        error           // причина прерывания выполнения
+	steps          int64           // выполнено инструкций
 }
  
 
-//создание
-func NewInterpreter(settingsFile string, baseInput, baseOutput, baseAssign int, debug bool) *Interpreter {
+//создание по файлу настроек; ввод-вывод как у New: ввод пуст, вывод отбрасывается.
+//Нулевое основание берётся из настроек, а если там не задано - 10
+func NewInterpreter(settingsFile string, baseInput, baseOutput, baseAssign int, debug bool) (*Interpreter, error) {
+	dialect, err := LoadDialect(settingsFile)
+	if err != nil {
+		return nil, err
+	}
+	interpreter, err := New(dialect)
+	if err != nil {
+		return nil, err
+	}
+	interpreter.Debug, interpreter.SettingsFile = debug, settingsFile
+	for _, base := range []struct {
+		field *int
+		value int
+	}{{&interpreter.BaseInput, baseInput}, {&interpreter.BaseOutput, baseOutput}, {&interpreter.BaseAssign, baseAssign}} {
+		if base.value != 0 {
+			*base.field = base.value
+		}
+	}
+	return interpreter, nil
+}
+
+//создание по диалекту без обращения к файлам: ввод пуст, вывод отбрасывается, приглашений нет
+func New(d *Dialect) (*Interpreter, error) {
 	interpreter := &Interpreter{
-		Commands: map[string]string{
-			"not":    "not",
-			"input":  "input",
-			"output": "output",
-			"add":    "add",
-			"mult":   "mult",
-			"sub":    "sub",
-			"pow":    "pow",
-			"div":    "div",
-			"rem":    "rem",
-			"xor":    "xor",
-			"and":    "and",
-			"or":     "or",
-			"=":      "=",
-		},
 		Variables:      NewTrie(),
-		BaseInput:      baseInput,
-		BaseOutput:     baseOutput,
-		BaseAssign:     baseAssign,
-		Result:         "left",
-		UnarySyntax:    "op()",
-		BinarySyntax:   "op()",
-		Debug:          debug,
-		SettingsFile:   settingsFile,
-	}
-	interpreter.LoadSettings(settingsFile)
-	interpreter.SaveLastSettings()
-
+		VarHistory:     NewTrie(),
+		Functions:      make(map[string]*FuncDecl),
+		In:             strings.NewReader(""),
+		Out:            io.Discard,
+		BaseInput:      10,
+		BaseOutput:     10,
+		BaseAssign:     10,
+	}
+	interpreter.ApplyDialect(NewDialect())
+	if err := interpreter.ApplyDialect(d); err != nil {
+		return nil, err
+	}
 	for original := range interpreter.Commands {
 		interpreter.Oper = append(interpreter.Oper, original)
 	}
+	return interpreter, nil
+}
 
-	return interpreter
+//сброс состояния сеанса: переменные, функции, история значений, точки останова и наблюдения.
+//Настройки диалекта, оснований и ввода-вывода сохраняются
+func (interp *Interpreter) Reset() {
+	interp.Variables, interp.VarHistory, interp.Functions = NewTrie(), NewTrie(), make(map[string]*FuncDecl)
+	interp.Breakpoints, interp.Watches, interp.Stepping = nil, nil, false
+	interp.frames, interp.stepDepth, interp.current = nil, 0, nil
+	interp.resetLimits()
 }
 
 
@@ -83,478 +123,415 @@ func (interp *Interpreter) SaveLastSettings() error {
 
 //загрузка из файла 
 func (interp *Interpreter) LoadSettings(settingsFile string) error {
-	file, err := os.Open(settingsFile)
+	dialect, err := LoadDialect(settingsFile)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		switch line {
-		case "left=":
-			interp.Result = "left"
-		case "right=":
-			interp.Result = "right"
-		case "op()", "()op":
-			interp.BinarySyntax = line
-			interp.UnarySyntax = line
-		case "(op)":
-			interp.BinarySyntax = line
-		default:
-			parts := strings.Fields(line)
-			if len(parts) == 2 {
-				interp.Commands[parts[0]] = parts[1]
-			} else if len(parts) == 3 && strings.HasPrefix(parts[0], "[") {
-				key := parts[1]
-				value := strings.TrimRight(parts[2], "]")
-				interp.Commands[key] = value
-			}
-		}
-	}
+	return interp.ApplyDialect(dialect)
+}
 
-	if err := scanner.Err(); err != nil {
+//применение диалекта
+func (interp *Interpreter) ApplyDialect(d *Dialect) error {
+	numeric, err := NewNumericBackend(d.Numeric)
+	if err != nil {
 		return err
 	}
-
+	interp.Commands = d.Commands
+	interp.Result = d.Result
+	interp.UnarySyntax = d.UnarySyntax
+	interp.BinarySyntax = d.BinarySyntax
+	interp.Precedence = d.Precedence
+	interp.RightAssoc = d.RightAssoc
+	interp.Numeric = numeric
+	if d.BaseInput != 0 {
+		interp.BaseInput = d.BaseInput
+	}
+	if d.BaseOutput != 0 {
+		interp.BaseOutput = d.BaseOutput
+	}
+	if d.BaseAssign != 0 {
+		interp.BaseAssign = d.BaseAssign
+	}
 	return nil
 }
 
+//действующие настройки как диалект
+func (interp *Interpreter) CurrentDialect() *Dialect {
+	d := NewDialect()
+	for op, synonym := range interp.Commands {
+		d.Commands[op] = synonym
+	}
+	d.Result = interp.Result
+	d.UnarySyntax = interp.UnarySyntax
+	d.BinarySyntax = interp.BinarySyntax
+	for op, level := range interp.Precedence {
+		d.Precedence[op] = level
+	}
+	for op, right := range interp.RightAssoc {
+		d.RightAssoc[op] = right
+	}
+	d.Numeric = interp.Numeric.Name()
+	d.BaseInput = interp.BaseInput
+	d.BaseOutput = interp.BaseOutput
+	d.BaseAssign = interp.BaseAssign
+	return d
+}
+
 //разделение по командам
-func (interp *Interpreter) Execute(program string) {
-	lines := strings.Split(program, ";")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		line = interp.RemoveNestedComments(line)
-		if line != "" {
-			if interp.Debug && strings.Contains(line, "#BREAKPOINT") {
-				interp.DebugPrompt()
-				line = strings.ReplaceAll(line, "#BREAKPOINT", "")
-			}
+func (interp *Interpreter) Execute(program string) error {
+	return interp.ExecuteContext(context.Background(), program)
+}
 
-			line = interp.RemoveComments(line)
-			interp.ProcessLine(line)
+//выполнение с отменой через контекст: после отмены возвращается ошибка контекста
+func (interp *Interpreter) ExecuteContext(ctx context.Context, program string) error {
+	var diags Diagnostics
+	prog, err := interp.Parse(interp.ProgramFile, program)
+	if err != nil {
+		diags = append(diags, err.(Diagnostics)...)
+		if !interp.KeepGoing {
+			return diags
 		}
 	}
-}
 
-//удаление комментария
-func (interp *Interpreter) RemoveComments(line string) string {
-	commentIndex := strings.Index(line, "#")
-	if commentIndex != -1 {
-		line = line[:commentIndex]
+	if interp.Optimize && !interp.Debug {
+		prog, _ = interp.OptimizeProgram(prog)
 	}
-	return strings.TrimSpace(line)
+	return interp.run(ctx, prog, nil, diags)
 }
 
+// выполнение разобранной программы; bc - уже скомпилированный байт-код или nil
+func (interp *Interpreter) run(ctx context.Context, prog *Program, bc *Bytecode, diags Diagnostics) error {
+	if interp.Limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, interp.Limits.Timeout, ErrTimeLimit)
+		defer cancel()
+	}
+	interp.ctx = ctx
+	interp.resetLimits()
+	defer func() { interp.ctx = nil }()
+	interp.declareFunctions(prog.Stmts)
+	// отладчику нужны остановки на инструкциях дерева, режим big байт-код не поддерживает
+	if !interp.Debug && !interp.TreeWalk {
+		if bc == nil {
+			bc, _ = interp.Compile(prog)
+		}
+		if bc != nil {
+			interp.runBytecode(bc, &diags)
+			return interp.runError(diags)
+		}
+	}
+	interp.executeBlock(prog.Stmts, &diags)
+	return interp.runError(diags)
+}
 
-//удаление блока комментарий
-func (interp *Interpreter) RemoveNestedComments(text string) string {
-	pattern := `\[[^\[\]]*?\]`
-	re := regexp.MustCompile(pattern)
-	for re.MatchString(text) {
-		text = re.ReplaceAllString(text, "")
+// итог выполнения: превышение ограничения добавляется к диагностикам,
+// отмена через контекст важнее ошибок в инструкциях
+func (interp *Interpreter) runError(diags Diagnostics) error {
+	var d *Diagnostic
+	if errors.As(interp.stopErr, &d) {
+		return append(diags, d)
+	}
+	if interp.stopErr != nil {
+		return interp.stopErr
 	}
-	return text
+	return diags.Err()
 }
 
-//обработка команд
-func (interp *Interpreter) ProcessLine(line string) {
-	for original, synonym := range interp.Commands {
-		str1 := synonym + "("
-		str2 := ")" + synonym
-		str3 := " " + synonym + " "
-		if strings.Contains(line, str1) || strings.Contains(line, str2) || strings.Contains(line, str3) {
-			line = strings.ReplaceAll(line, synonym, original)
-		}
-	}
-
-	if strings.Contains(line, "=") {
-		parts := strings.SplitN(line, "=", 2)
-		left := strings.TrimSpace(parts[0])
-		right := strings.TrimSpace(parts[1])
-
-		if interp.Result == "left" {
-			variable, expression := left, right
-			if strings.Contains(line, "input()") {
-				fmt.Printf("Enter value for %s: ", variable)
-				var inputVal int
-				fmt.Scanln(&inputVal)
-				interp.Variables.Insert(variable, inputVal)
-			} else {
-				value := interp.EvaluateExpression(expression)
-				interp.Variables.Insert(variable, value)
-			}
+// выполнение отменено через контекст перед инструкцией stmt; причина сохраняется в stopErr
+func (interp *Interpreter) interrupted(stmt Stmt) bool {
+	if interp.stopErr == nil && interp.ctx != nil && interp.ctx.Err() != nil {
+		if context.Cause(interp.ctx) == ErrTimeLimit {
+			interp.exceed(timeLimit(stmt, interp.Limits.Timeout), stmt)
 		} else {
-			expression, variable := left, right
-			if strings.Contains(line, "input()") {
-				fmt.Printf("Enter value for %s: ", variable)
-				var inputVal int
-				fmt.Scanln(&inputVal)
-				interp.Variables.Insert(variable, inputVal)
-			} else {
-				value := interp.EvaluateExpression(expression)
-				interp.Variables.Insert(variable, value)
-			}
+			interp.stopErr = interp.ctx.Err()
 		}
-	} else {
-		expression := strings.TrimSpace(line)
-		interp.EvaluateExpression(expression)
 	}
+	return interp.stopErr != nil
 }
 
-//вычисляем выражение
-func (interp *Interpreter) EvaluateExpression(expr string) int {
-	if strings.Contains(expr, "output") {
-		expr = strings.TrimSpace(expr)
-
-		var varName string
-		if strings.HasPrefix(expr, "output") {
-			if interp.UnarySyntax != "op()" {
-				panic("Ошибка: недопустимое расположение операндов и операций")
+// выполнение инструкций блока; false - выполнение прервано ошибкой или отладчиком
+func (interp *Interpreter) executeBlock(stmts []Stmt, diags *Diagnostics) bool {
+	for _, stmt := range stmts {
+		if interp.halted(stmt) {
+			return false
+		}
+		interp.current = stmt
+		if interp.Debug {
+			if reason := interp.stopReason(stmt); reason != "" && !interp.breakpoint(stmt, reason) {
+				return false
 			}
-			varName = strings.TrimSpace(expr[7 : len(expr)-1])
-		} else if strings.HasSuffix(expr, "output") {
-			if interp.UnarySyntax != "()op" {
-				panic("Ошибка: недопустимое расположение операндов и операций")
+		}
+		var err error
+		switch s := stmt.(type) {
+		case *IfStmt:
+			var cond bool
+			if cond, err = interp.condition(s.Cond); err == nil {
+				body := s.Then
+				if !cond {
+					body = s.Else
+				}
+				if body != nil && !interp.executeBlock(body.Stmts, diags) {
+					return false
+				}
 			}
-			varName = strings.TrimSpace(expr[1 : len(expr)-7])
+		case *WhileStmt:
+			if !interp.executeWhile(s, diags) {
+				return false
+			}
+		default:
+			err = interp.ExecuteStatement(stmt)
 		}
-
-		if strings.Contains(varName, "output") || strings.Contains(varName, "input") {
-			panic("ошибка")
+		if errors.Is(err, errStopped) {
+			return false
 		}
-
-		value := interp.EvaluateExpression(varName)
-		baseOutputValue := interp.DecimalToBase(value, interp.BaseOutput)
-		fmt.Printf("%s = %s\n", varName, baseOutputValue)
-		return value
-	} else if matched, _ := regexp.MatchString(`^[0-9A-Fa-f]+$`, expr); matched {
-		num, err := strconv.ParseInt(expr, 16, 64)
 		if err != nil {
-			panic(fmt.Sprintf("Ошибка при парсинге шестнадцатеричного числа: %v", err))
+			*diags = append(*diags, stmtDiagnostic(err, stmt))
+			if !interp.KeepGoing {
+				return false
+			}
+		}
+		if interp.Debug && interp.updateWatches() && !interp.breakpoint(stmt, "watch") {
+			return false
+		}
+		if interp.returning() {
+			return true
 		}
-		return int(num)
-	} else {
-		return int(interp.EvaluateInfix(expr))
 	}
+	return true
 }
 
-//10
-func (interp *Interpreter) DecimalToBase(num, base int) string {
-	if num == 0 {
-		return "0"
+// цикл while; перед каждой следующей проверкой условия отладчик снова может остановиться на заголовке.
+// Ошибка в условии завершает цикл даже в режиме --keep-going
+func (interp *Interpreter) executeWhile(s *WhileStmt, diags *Diagnostics) bool {
+	for first := true; ; first = false {
+		if !first && interp.halted(s) {
+			return false
+		}
+		if interp.Debug && !first {
+			if reason := interp.stopReason(s); reason != "" && !interp.breakpoint(s, reason) {
+				return false
+			}
+		}
+		cond, err := interp.condition(s.Cond)
+		if errors.Is(err, errStopped) {
+			return false
+		}
+		if err != nil {
+			*diags = append(*diags, stmtDiagnostic(err, s))
+			return interp.KeepGoing
+		}
+		if !cond {
+			return true
+		}
+		if !interp.executeBlock(s.Body.Stmts, diags) {
+			return false
+		}
+		if interp.returning() {
+			return true
+		}
 	}
+}
 
-	digits := "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	var result strings.Builder
-
-	for num != 0 {
-		remainder := num % base
-		result.WriteByte(digits[remainder])
-		num = num / base
+// условие if и while: истинно любое ненулевое значение
+func (interp *Interpreter) condition(expr Expr) (bool, error) {
+	value, err := interp.EvaluateExpression(expr)
+	if err != nil {
+		return false, err
 	}
+	return interp.Numeric.Compare(value, interp.Numeric.FromInt64(0)) != 0, nil
+}
 
-	// Переворачиваем строку, так как result записывает цифры в обратном порядке
-	reversed := result.String()
-	runes := []rune(reversed)
-	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
+// ошибка выполнения как диагностика с номером инструкции
+func stmtDiagnostic(err error, stmt Stmt) *Diagnostic {
+	var d *Diagnostic
+	if !errors.As(err, &d) {
+		d = newDiagnostic(CodeSyntax, stmt.Pos(), "%v", err)
 	}
-
-	return string(runes)
+	if d.Stmt == 0 {
+		d.Stmt = stmt.Number() // ошибка в вызванной функции сохраняет номер своей инструкции
+	}
+	return d
 }
 
-
-func (interp *Interpreter) EvaluateInfix(expression string) float64 {
-	precedence := map[string]int{
-		"add": 1,
-		"sub": 1,
-		"mult": 2,
-		"div": 2,
-		"rem": 2,
-		"xor": 1,
-		"and": 1,
-		"or": 1,
-		"pow": 3,
+//синтаксис из настроек
+func (interp *Interpreter) Syntax() Syntax {
+	return Syntax{
+		Unary:      interp.UnarySyntax,
+		Binary:     interp.BinarySyntax,
+		Result:     interp.Result,
+		Precedence: interp.Precedence,
+		RightAssoc: interp.RightAssoc,
 	}
+}
 
-	higherPrecedence := func(op1, op2 string) bool {
-		return precedence[op1] >= precedence[op2]
+//разбор программы в дерево
+func (interp *Interpreter) Parse(file, program string) (*Program, error) {
+	p := NewParser(NewLexer(file, program, interp.Commands), interp.Syntax())
+	p.maxNesting, p.maxLiteral = interp.Limits.MaxNesting, interp.Limits.MaxLiteralLen
+	for name, decl := range interp.Functions {
+		p.funcs[name] = len(decl.Params)
 	}
+	return p.ParseProgram()
+}
 
-	var postfix []string
-	var stack []string
-
-	tokens := interp.Tokenize(expression)
-
-	for _, token := range tokens {
-		if interp.IsNumber(token) {
-			postfix = append(postfix, token)
-		} else if token == "(" {
-			stack = append(stack, token)
-		} else if token == ")" {
-			for len(stack) > 0 && stack[len(stack)-1] != "(" {
-				postfix = append(postfix, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
+//обработка команд
+func (interp *Interpreter) ExecuteStatement(stmt Stmt) error {
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		variable := s.Name.Text
+		if call, ok := s.Value.(*CallExpr); ok && call.Op.Op == "input" {
+			inputVal, err := interp.readInput(variable, call)
+			if err == nil {
+				err = interp.defineVariable(s)
 			}
-			stack = stack[:len(stack)-1] // Pop "(" from stack
-		} else if _, ok := precedence[token]; ok {
-			for len(stack) > 0 && stack[len(stack)-1] != "(" && higherPrecedence(stack[len(stack)-1], token) {
-				postfix = append(postfix, stack[len(stack)-1])
-				stack = stack[:len(stack)-1]
+			if err != nil {
+				return err
 			}
-			stack = append(stack, token)
+			interp.setVariable(variable, inputVal, stmt)
 		} else {
-			panic("Invalid token: " + token)
+			value, err := interp.EvaluateExpression(s.Value)
+			if err == nil {
+				err = interp.defineVariable(s)
+			}
+			if err != nil {
+				return err
+			}
+			interp.setVariable(variable, value, stmt)
+		}
+	case *ExprStmt:
+		_, err := interp.EvaluateExpression(s.X)
+		return err
+	case *FuncDecl:
+		interp.Functions[s.Name.Text] = s
+	case *ReturnStmt:
+		value, err := interp.EvaluateExpression(s.Value)
+		if err != nil {
+			return err
+		}
+		if f := interp.frame(); f != nil {
+			f.result, f.returned = value, true
 		}
 	}
+	return nil
+}
 
-	for len(stack) > 0 {
-		postfix = append(postfix, stack[len(stack)-1])
-		stack = stack[:len(stack)-1]
+// ввод значения переменной name в основании BaseInput
+func (interp *Interpreter) readInput(name string, call *CallExpr) (Value, error) {
+	if interp.Prompt != nil {
+		fmt.Fprintf(interp.Prompt, "Enter value for %s: ", name)
 	}
-
-	result, err := interp.EvalPostfix(postfix)
+	// значение - вся строка ввода, лишнее не переходит в следующий input
+	inputText, ok := readRawLine(interp.In)
+	if !ok {
+		return nil, newDiagnostic(CodeBadInput, call.Span, "нет значения для %s: ввод закончился", name)
+	}
+	if strings.ContainsAny(inputText, " \t") {
+		return nil, newDiagnostic(CodeBadInput, call.Span, "некорректное значение для %s: ожидалось одно число, введено %q", name, inputText)
+	}
+	if limit := interp.Limits.MaxLiteralLen; limit > 0 && len(inputText) > limit {
+		return nil, interp.exceed(literalLimit(call.Span, limit), interp.current)
+	}
+	value, err := interp.Numeric.Parse(inputText, interp.BaseInput)
 	if err != nil {
-		panic("Error evaluating postfix expression: " + err.Error())
+		return nil, newDiagnostic(CodeBadInput, call.Span, "некорректное значение для %s: %v", name, err)
 	}
-
-	return result
+	return value, nil
 }
 
-
-
-//проверка на число
-func (interp *Interpreter) IsNumber(s string) bool {
-	_, err := strconv.ParseFloat(s, 64)
-	return err == nil
+// вывод значения аргумента output в основании BaseOutput
+func (interp *Interpreter) writeOutput(call *CallExpr, value Value) {
+	fmt.Fprintf(interp.Out, "%s = %s\n", call.ArgText, interp.DecimalToBase(value, interp.BaseOutput))
 }
 
-
-
-//токен
-func (interp *Interpreter) Tokenize(expression string, evaluateInfix func(string) float64, executeCommand func(string, []string) string, variables map[string]interface{}) []string {
-	expression = strings.TrimSpace(expression)
-	var tokens []string
-	var currentToken string
-	var openB int
-	i := 0
-	for i != len(expression) {
-		if expression[i] == ' ' && currentToken != "" && openB == 0 {
-			if contains([]string{"add", "sub", "pow", "div", "rem", "xor", "and"}, currentToken) && binarySyntax != "(op)" {
-				panic("Ошибка: недопустимое расположение операндов и операций")
-			}
-			tokens = append(tokens, currentToken)
-			currentToken = ""
-		} else if !strings.Contains("()", string(expression[i])) && expression[i] != ' ' {
-			currentToken += string(expression[i])
-		} else if expression[i] == '(' {
-			currentToken += string(expression[i])
-			openB++
-		} else if expression[i] == ')' && openB != 0 {
-			currentToken += string(expression[i])
-			openB--
-			if openB == 0 {
-				var funcName string
-				var args []string
-				var current string
-				var open int
-				for j := 0; j < len(currentToken); j++ {
-					if currentToken[j] == '(' {
-						open++
-						current += string(currentToken[j])
-					} else if currentToken[j] == ')' {
-						open--
-						current += string(currentToken[j])
-					} else if currentToken[j] == ',' && open == 0 {
-						args = append(args, current)
-						current = ""
-					} else {
-						current += string(currentToken[j])
-					}
-					if j == len(currentToken)-1 {
-						args = append(args, current)
-					}
-				}
-
-				if funcName == "" && len(args) == 1 {
-					st := expression[i+1:]
-					if strings.HasPrefix(st, "not") {
-						if UnarySyntax != "()op" {
-							panic("Ошибка: недопустимое расположение операндов и операций")
-						} else {
-							funcName = "not"
-							i += 3
-						}
-					}
-				} else if funcName == "" && len(args) > 1 {
-					if BinarySyntax != "()op" {
-						panic("Ошибка: недопустимое расположение операндов и операций")
-					}
-
-					st := expression[i+1:]
-					switch {
-					case strings.HasPrefix(st, "add"):
-						funcName = "add"
-						i += 3
-					case strings.HasPrefix(st, "sub"):
-						funcName = "sub"
-						i += 3
-					case strings.HasPrefix(st, "pow"):
-						funcName = "pow"
-						i += 3
-					case strings.HasPrefix(st, "div"):
-						funcName = "div"
-						i += 3
-					case strings.HasPrefix(st, "rem"):
-						funcName = "rem"
-						i += 3
-					case strings.HasPrefix(st, "xor"):
-						funcName = "xor"
-						i += 3
-					case strings.HasPrefix(st, "and"):
-						funcName = "and"
-						i += 3
-					case strings.HasPrefix(st, "mult"):
-						funcName = "mult"
-						i += 4
-					case strings.HasPrefix(st, "or"):
-						funcName = "or"
-						i += 2
-					}
-				}
-
-				if funcName == "" {
-					tokens = append(tokens, fmt.Sprintf("%v", evaluateInfix(args[0])))
-					currentToken = ""
-				} else {
-					for k := range args {
-						args[k] = fmt.Sprintf("%v", evaluateInfix(args[k]))
-					}
-					tokens = append(tokens, executeCommand(funcName, args))
-					currentToken = ""
-				}
-			}
-		} else if currentToken != "" && openB != 0 {
-			currentToken += string(expression[i])
-		} else if currentToken != "" {
-			tokens = append(tokens, currentToken)
-			tokens = append(tokens, string(expression[i]))
-			currentToken = ""
-		}
-		i++
-	}
-	if currentToken != "" {
-		tokens = append(tokens, currentToken)
-	}
-
-	for i := range tokens {
-		if val, ok := variables[tokens[i]]; ok {
-			tokens[i] = fmt.Sprintf("%v", val)
-		}
+// литерал в BaseAssign
+func (interp *Interpreter) literal(e *NumberLit) (Value, error) {
+	num, err := interp.Numeric.Parse(e.Text, interp.BaseAssign)
+	if err != nil {
+		return nil, newDiagnostic(CodeBadLiteral, e.Span, "%v", err)
 	}
-	return tokens
+	return num, nil
 }
 
+// ошибки выполнения, общие для обхода дерева и байт-кода
+func undefinedVariable(e *Ident) error {
+	return newDiagnostic(CodeUndefinedVar, e.Span, "переменная %s не объявлена", e.Text)
+}
 
-//
-func (interp *Interpreter) EvalPostfix(expression interface{}, oper map[string]bool, executeCommand func(string, []string) string) (float64, error) {
-	stack := []float64{}
+func divisionByZero(e *BinaryExpr) error {
+	return newDiagnostic(CodeDivisionByZero, e.Span, "деление на ноль в %s", e.Op.Text)
+}
 
-	// Convert expression to tokens based on its type
-	var tokens []string
-	switch v := expression.(type) {
-	case int:
-		tokens = strings.Split(strconv.Itoa(v), "")
-	case string:
-		tokens = strings.Split(v, "")
-	default:
-		return 0.0, fmt.Errorf("unsupported expression type")
-	}
+func misplacedIO(e *CallExpr) error {
+	return newDiagnostic(CodeMisplacedIO, e.Span, "%s допустим только в правой части присваивания", e.Op.Text)
+}
 
-	for _, token := range tokens {
-		if interp.IsNumber(token) {
-			num, err := strconv.ParseFloat(token, 64)
-			if err != nil {
-				return 0.0, err
-			}
-			stack = append(stack, num)
-		} else if oper[token] {
-			if len(stack) < 2 {
-				return 0.0, fmt.Errorf("invalid expression")
-			}
-			operand2 := stack[len(stack)-1]
-			operand1 := stack[len(stack)-2]
-			stack = stack[:len(stack)-2] // Pop two elements from stack
-
-			// Execute the operation
-			args := []string{fmt.Sprintf("%v", operand1), fmt.Sprintf("%v", operand2)}
-			result := executeCommand(token, args)
-			numResult, err := strconv.ParseFloat(result, 64)
-			if err != nil {
-				return 0.0, err
-			}
-			stack = append(stack, numResult)
-		} else {
-			return 0.0, fmt.Errorf("invalid token: %v", token)
+//вычисляем выражение
+func (interp *Interpreter) EvaluateExpression(expr Expr) (Value, error) {
+	switch e := expr.(type) {
+	case *NumberLit:
+		return interp.literal(e)
+	case *Ident:
+		value := interp.lookup(e.Text)
+		if value == nil {
+			return nil, undefinedVariable(e)
 		}
+		return value, nil
+	case *UnaryExpr:
+		arg, err := interp.EvaluateExpression(e.X)
+		if err != nil {
+			return nil, err
+		}
+		result, err := interp.ExecuteCommand(e.Op.Op, []Value{arg})
+		if err == nil && interp.resultTooLarge(result) {
+			return nil, interp.exceed(valueLimit(e.Span, interp.Limits.valueBits()), interp.current)
+		}
+		return result, err
+	case *BinaryExpr:
+		arg1, err := interp.EvaluateExpression(e.X)
+		if err != nil {
+			return nil, err
+		}
+		arg2, err := interp.EvaluateExpression(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		args := []Value{arg1, arg2}
+		if interp.valueTooLarge(e.Op.Op, args) {
+			return nil, interp.exceed(valueLimit(e.Span, interp.Limits.valueBits()), interp.current)
+		}
+		result, err := interp.ExecuteCommand(e.Op.Op, args)
+		if err == errDivisionByZero {
+			return nil, divisionByZero(e)
+		}
+		if err == nil && interp.resultTooLarge(result) {
+			return nil, interp.exceed(valueLimit(e.Span, interp.Limits.valueBits()), interp.current)
+		}
+		return result, err
+	case *FuncCall:
+		return interp.callFunction(e)
+	case *CallExpr:
+		if e.Op.Op != "output" {
+			return nil, misplacedIO(e)
+		}
+		value, err := interp.EvaluateExpression(e.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		interp.writeOutput(e, value)
+		return value, nil
+	default:
+		return nil, newDiagnostic(CodeSyntax, expr.Pos(), "неизвестное выражение")
 	}
-
-	if len(stack) != 1 {
-		return 0.0, fmt.Errorf("invalid expression")
-	}
-
-	return stack[0], nil
 }
 
-//
-func split2str(s, sep string, n int) []string {
-	parts := strings.SplitN(s, sep, n)
-	for i := range parts {
-		parts[i] = strings.TrimSpace(parts[i])
-	}
-	return parts
+//10
+func (interp *Interpreter) DecimalToBase(value Value, base int) string {
+	return interp.Numeric.Format(value, base)
 }
 
 
-func (interp *Interpreter) ExecuteCommand(cmd string, args []string) int {
-	if cmd == "not" {
-		arg := interp.EvaluateExpression(args[0])
-		return ^arg & 0xFFFFFFFF
-	}
-
-	arg1 := interp.EvaluateExpression(args[0])
-	arg2 := interp.EvaluateExpression(args[1])
-
-	switch cmd {
-	case "add":
-		return (arg1 + arg2) & 0xFFFFFFFF
-	case "mult":
-		return (arg1 * arg2) & 0xFFFFFFFF
-	case "sub":
-		return (arg1 - arg2) & 0xFFFFFFFF
-	case "div":
-		return arg1 / arg2
-	case "rem":
-		return arg1 % arg2
-	case "xor":
-		return arg1 ^ arg2
-	case "and":
-		return arg1 & arg2
-	case "or":
-		return arg1 | arg2
-	case "pow":
-		return int(math.Pow(float64(arg1), float64(arg2))) & 0xFFFFFFFF
-	default:
-		return 0
-	}
+func (interp *Interpreter) ExecuteCommand(cmd string, args []Value) (Value, error) {
+	return interp.Numeric.Apply(cmd, args)
 }
 
 
@@ -617,127 +594,161 @@ func (interp *Interpreter) ZeckendorfToInt(fibNums []int) int {
 }
 
 
-func (interp *Interpreter) DebugPrompt() {
-	fmt.Println("Доступные команды:")
-	fmt.Println("1) Вывод значения и двоичного представления переменной")
-	fmt.Println("2) Вывести все переменные")
-	fmt.Println("3) Обновить значение существующей переменной")
-	fmt.Println("4) Объявить новую переменную")
-	fmt.Println("5) Удалить переменную")
-	fmt.Println("6) Продолжить выполнение кода")
-	fmt.Println("7) Завершить работу интерпретатора")
+//интерактивное меню отладчика: команды читаются из in, ответы выводятся в out;
+//false - завершить выполнение программы
+func (interp *Interpreter) DebugPrompt(in io.Reader, out io.Writer) bool {
+	fmt.Fprintln(out, "Доступные команды:")
+	fmt.Fprintln(out, "1) Вывод значения и двоичного представления переменной")
+	fmt.Fprintln(out, "2) Вывести все переменные")
+	fmt.Fprintln(out, "3) Обновить значение существующей переменной")
+	fmt.Fprintln(out, "4) Объявить новую переменную")
+	fmt.Fprintln(out, "5) Удалить переменную")
+	fmt.Fprintln(out, "6) Продолжить выполнение кода")
+	fmt.Fprintln(out, "7) Завершить работу интерпретатора")
+	fmt.Fprintln(out, "break <инструкция>|line <строка> [if <условие>], clear <место>, breaks - точки останова")
+	fmt.Fprintln(out, "step - выполнить одну инструкцию, next - без захода в функции, finish - до выхода из функции, stack - стек вызовов")
+	fmt.Fprintln(out, "watch <выражение>, unwatch <номер>, watches - наблюдение, history <переменная> - история изменений")
 
 	for {
-		var command string
-		fmt.Print("DEBUG> ")
-		fmt.Scanln(&command)
-		command = strings.TrimSpace(strings.ToLower(command))
+		command, ok := readConsoleLine(in, out, "DEBUG> ", interp.Complete)
+		if !ok {
+			return true // ввод закончился - выполнение продолжается
+		}
+		if output, resume, handled, err := interp.debuggerCommand(command); handled {
+			if err != nil {
+				fmt.Fprintln(out, err)
+			} else if len(output) != 0 {
+				fmt.Fprintln(out, strings.Join(output, "\n"))
+			}
+			if resume {
+				return true
+			}
+			continue
+		}
 
 		switch command {
 		case "1":
-			var varName string
-			fmt.Print("Введите имя переменной: ")
-			fmt.Scanln(&varName)
-			value := interp.Variables.Search(varName)
-			if value != nil {
-				fmt.Printf("%s = %d\n", varName, *value)
-				binaryValue := fmt.Sprintf("%032b", *value)
-				fmt.Println(strings.Join(splitByWidth(binaryValue, 8), " "))
+			varName := interp.readVarName(in, out, "Введите имя переменной: ")
+			if output, err := interp.debugShow(varName); err == nil {
+				fmt.Fprintln(out, strings.Join(output, "\n"))
 			} else {
-				fmt.Println("Переменная не объявлена")
+				fmt.Fprintln(out, "Переменная не объявлена")
 			}
 
 		case "2":
 			for _, varName := range interp.Variables.ObtainAll() {
 				value := interp.Variables.Search(varName)
-				fmt.Printf("%s = %d\n", varName, *value)
+				fmt.Fprintf(out, "%s = %s\n", varName, interp.DecimalToBase(value, 10))
 			}
 
 		case "3":
-			var varName, hexValue string
-			fmt.Print("Введите имя переменной: ")
-			fmt.Scanln(&varName)
-			if contains(varName, interp.Variables.ObtainAll()) {
-				fmt.Print("Введите шестнадцатеричное значение переменной: ")
-				fmt.Scanln(&hexValue)
-				value, err := strconv.ParseInt(hexValue, 16, 32)
-				if err == nil {
-					interp.Variables.Insert(varName, int(value))
-					fmt.Printf("Значение переменной \"%s\" обновлено\n", varName)
+			varName := interp.readVarName(in, out, "Введите имя переменной: ")
+			if interp.Variables.Search(varName) != nil {
+				hexValue, _ := readConsoleLine(in, out, "Введите шестнадцатеричное значение переменной: ", nil)
+				if interp.debugSet(varName, hexValue) == nil {
+					fmt.Fprintf(out, "Значение переменной \"%s\" обновлено\n", varName)
 				} else {
-					fmt.Println("Некорректное значение")
+					fmt.Fprintln(out, "Некорректное значение")
 				}
 			} else {
-				fmt.Printf("Переменная \"%s\" не объявлена\n", varName)
+				fmt.Fprintf(out, "Переменная \"%s\" не объявлена\n", varName)
 			}
 
 		case "4":
-			var varName, valueType string
-			fmt.Print("Введите имя новой переменной: ")
-			fmt.Scanln(&varName)
-			allVars := interp.variables.ObtainAll()
-			for contains(varName, allVars) {
-				fmt.Println("Переменная уже объявлена. Введите другое имя переменной.")
-				fmt.Print("Введите имя новой переменной: ")
-				fmt.Scanln(&varName)
+			varName := interp.readVarName(in, out, "Введите имя новой переменной: ")
+			for varName == "" || interp.Variables.Search(varName) != nil {
+				if varName != "" {
+					fmt.Fprintln(out, "Переменная уже объявлена. Введите другое имя переменной.")
+				}
+				varName = interp.readVarName(in, out, "Введите имя новой переменной: ")
 			}
 
-			fmt.Print("Введите тип значения (цекендорфский(1)/римский(2)): ")
-			fmt.Scanln(&valueType)
+			valueType, _ := readConsoleLine(in, out, "Введите тип значения (цекендорфский(1)/римский(2)): ", nil)
 
 			switch valueType {
 			case "1":
-				fibSequence := interp.FibSequence(1000000)
 				for {
 					var fibNums []int
-					fmt.Print("Введите число в цекендорфовом представлении: ")
-					input := ""
-					fmt.Scanln(&input)
-					for _, num := range strings.Split(input, " ") {
+					input, ok := readConsoleLine(in, out, "Введите число в цекендорфовом представлении: ", nil)
+					if !ok {
+						return true
+					}
+					for _, num := range strings.Fields(input) {
 						n, _ := strconv.Atoi(num)
 						fibNums = append(fibNums, n)
 					}
-					if interp.IsZeckendorf(fibNums, fibSequence) {
-						value := interp.ZeckendorfToInt(fibNums)
-						interp.variables.Insert(varName, value)
-						fmt.Printf("Переменная %s объявлена со значением %d.\n", varName, value)
+					if value, err := interp.debugDeclareZeckendorf(varName, fibNums); err == nil {
+						fmt.Fprintf(out, "Переменная %s объявлена со значением %d.\n", varName, value)
 						break
 					} else {
-						fmt.Println("Недопустимое цекендорфово представление. Попробуйте снова.")
+						fmt.Fprintln(out, "Недопустимое цекендорфово представление. Попробуйте снова.")
 					}
 				}
 			case "2":
-				var romanValue string
-				fmt.Print("Введите значение римскими цифрами: ")
-				fmt.Scanln(&romanValue)
-				value := interp.RomanToInt(strings.ToUpper(romanValue))
-				interp.variables.Insert(varName, value)
-				fmt.Printf("Переменная %s объявлена со значением %d.\n", varName, value)
+				romanValue, _ := readConsoleLine(in, out, "Введите значение римскими цифрами: ", nil)
+				value, _ := interp.debugDeclareRoman(varName, romanValue)
+				fmt.Fprintf(out, "Переменная %s объявлена со значением %d.\n", varName, value)
 			default:
-				fmt.Println("Неизвестный тип значения")
+				fmt.Fprintln(out, "Неизвестный тип значения")
 			}
 
 		case "5":
-			var varName string
-			fmt.Print("Введите имя переменной: ")
-			fmt.Scanln(&varName)
-			if interp.variables.Search(varName) != nil {
-				interp.variables.Delete(varName)
-				fmt.Printf("Переменная \"%s\" удалена\n", varName)
+			varName := interp.readVarName(in, out, "Введите имя переменной: ")
+			if interp.debugDelete(varName) == nil {
+				fmt.Fprintf(out, "Переменная \"%s\" удалена\n", varName)
 			} else {
-				fmt.Printf("Переменная \"%s\" не объявлена\n", varName)
+				fmt.Fprintf(out, "Переменная \"%s\" не объявлена\n", varName)
 			}
 
 		case "6":
-			return
+			return true
 		case "7":
-			os.Exit(0)
+			return false
 		default:
-			fmt.Println("Неизвестная команда")
+			fmt.Fprintln(out, "Неизвестная команда")
 		}
 	}
 }
 
+// чтение имени переменной через лексер; "" если введено не имя
+func (interp *Interpreter) readVarName(in io.Reader, out io.Writer, prompt string) string {
+	input, _ := readConsoleLine(in, out, prompt, interp.Complete)
+	tokens, err := NewLexer("", input, interp.Commands).All()
+	if err != nil || len(tokens) != 2 || tokens[0].Kind != TokIdent {
+		fmt.Fprintln(out, "Некорректное имя переменной")
+		return ""
+	}
+	return tokens[0].Text
+}
+
+// варианты дополнения: переменные, функции и синонимы операций с префиксом prefix
+func (interp *Interpreter) Complete(prefix string) []string {
+	synonyms := NewTrie()
+	for original, synonym := range interp.Commands {
+		if synonym != "" {
+			synonyms.Insert(synonym, original)
+		}
+	}
+	candidates := interp.Variables.KeysWithPrefix(prefix)
+	for name := range interp.Functions {
+		if strings.HasPrefix(name, prefix) && interp.Variables.Search(name) == nil {
+			candidates = append(candidates, name)
+		}
+	}
+	for _, synonym := range synonyms.KeysWithPrefix(prefix) {
+		if interp.Variables.Search(synonym) == nil {
+			candidates = append(candidates, synonym)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
 func splitByWidth(s string, width int) []string {
 	var result []string
 	for i := 0; i < len(s); i += width {
@@ -750,52 +761,36 @@ func splitByWidth(s string, width int) []string {
 	return result
 }
 
+// Параметры интерпретатора поверх файла настроек; нулевые значения - как в настройках
+type Options struct {
+	BaseInput    int
+	BaseOutput   int
+	BaseAssign   int
+	Numeric      string // "32", "64" или "big"
+	Debug        bool
+	KeepGoing    bool
+	Optimize     bool
+	Quiet        bool // без приглашений перед вводом в командной строке и в отладчике DAP
+	MaxCallDepth int  // 0 - defaultMaxCallDepth
+	Limits       Limits
+}
 
-
-
-
-
-
-
-
-func main() {
-	args := os.Args
-	if len(args) < 3 {
-		fmt.Println("Usage: go run interpreter.go <settings_file> <program_file> [--debug|-d|/debug] [base-assign=<value>] [base-input=<value>] [base-output=<value>]")
-		os.Exit(1)
-	}
-
-	programFile := args[1]
-	settingsFile := args[2]
-
-	baseAssign := 10
-	baseInput := 10
-	baseOutput := 10
-
-	// Parse command-line arguments for base values
-	for _, arg := range args[3:] {
-		if strings.HasPrefix(arg, "base-assign") {
-			baseAssignStr := strings.Split(arg, "=")[1]
-			baseAssign, _ = strconv.Atoi(baseAssignStr)
-		} else if strings.HasPrefix(arg, "base-input") {
-			baseInputStr := strings.Split(arg, "=")[1]
-			baseInput, _ = strconv.Atoi(baseInputStr)
-		} else if strings.HasPrefix(arg, "base-output") {
-			baseOutputStr := strings.Split(arg, "=")[1]
-			baseOutput, _ = strconv.Atoi(baseOutputStr)
-		}
-	}
-
-	debug := containsAny(args, "--debug", "-d", "/debug")
-
-	// Read program file
-	program, err := readFile(programFile)
+//создание по файлу настроек и параметрам
+func NewInterpreterWithOptions(settingsFile string, opts Options) (*Interpreter, error) {
+	interpreter, err := NewInterpreter(settingsFile, opts.BaseInput, opts.BaseOutput, opts.BaseAssign, opts.Debug)
 	if err != nil {
-		fmt.Println("Error reading program file:", err)
-		os.Exit(1)
+		return nil, err
+	}
+	interpreter.KeepGoing = opts.KeepGoing
+	interpreter.MaxCallDepth = opts.MaxCallDepth
+	interpreter.Optimize = opts.Optimize
+	interpreter.Limits = opts.Limits
+	if opts.Numeric != "" {
+		backend, err := NewNumericBackend(opts.Numeric)
+		if err != nil {
+			return nil, err
+		}
+		interpreter.Numeric = backend
 	}
-
-	// Create interpreter instance with settings and execute program
-	interpreter := NewInterpreter(settingsFile, baseInput, baseOutput, baseAssign, debug)
-	interpreter.Execute(program)
-}
\ No newline at end of file
+	return interpreter, nil
+}
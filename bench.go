@@ -0,0 +1,196 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const defaultBenchSize = 10000
+
+// Тест производительности: программа строится деревом и печатается в диалекте настроек
+type benchCase struct {
+	name  string
+	build func(g benchGen, size int) []Stmt
+}
+
+var benchCases = []benchCase{
+	{"выражения", benchExpressions},
+	{"цикл", benchLoop},
+	{"вызовы", benchCalls},
+}
+
+// построение дерева программы для теста; литералы записываются в основании присваивания
+type benchGen struct {
+	interp *Interpreter
+}
+
+func (g benchGen) num(n int64) Expr {
+	text := g.interp.DecimalToBase(g.interp.Numeric.FromInt64(n), g.interp.BaseAssign)
+	if text[0] < '0' || text[0] > '9' {
+		text = "0" + text
+	}
+	return &NumberLit{Token{Kind: TokNumber, Text: text}}
+}
+
+func (g benchGen) ident(name string) *Ident {
+	return &Ident{Token{Kind: TokIdent, Text: name}}
+}
+
+func (g benchGen) op(op string, x, y Expr) Expr {
+	return &BinaryExpr{Op: Token{Kind: TokOp, Op: op}, X: x, Y: y}
+}
+
+func (g benchGen) call(name string, args ...Expr) Expr {
+	return &FuncCall{Name: Token{Kind: TokIdent, Text: name}, Args: args}
+}
+
+func (g benchGen) assign(name string, value Expr) Stmt {
+	return &AssignStmt{Name: g.ident(name), Value: value}
+}
+
+func (g benchGen) block(stmts ...Stmt) *Block {
+	return &Block{Stmts: stmts}
+}
+
+// size присваиваний с арифметикой над скользящим набором переменных
+func benchExpressions(g benchGen, size int) []Stmt {
+	const vars = 50
+	var stmts []Stmt
+	for i := 0; i < vars; i++ {
+		stmts = append(stmts, g.assign(fmt.Sprintf("v%d", i), g.num(int64(i+1))))
+	}
+	for i := vars; i < size; i++ {
+		v := func(k int) Expr { return g.ident(fmt.Sprintf("v%d", k%vars)) }
+		value := g.op("xor", g.op("add", g.op("mult", v(i-1), g.num(3)), g.num(int64(i%100))), g.op("rem", v(i-7), g.num(13)))
+		stmts = append(stmts, g.assign(fmt.Sprintf("v%d", i%vars), value))
+	}
+	return stmts
+}
+
+// цикл на size*10 итераций
+func benchLoop(g benchGen, size int) []Stmt {
+	i, s := g.ident("i"), g.ident("s")
+	return []Stmt{
+		g.assign("s", g.num(0)),
+		g.assign("i", g.num(int64(size)*10)),
+		&WhileStmt{Cond: i, Body: g.block(
+			g.assign("s", g.op("xor", g.op("add", s, g.op("rem", g.op("mult", i, i), g.num(7))), g.op("and", i, g.num(255)))),
+			g.assign("i", g.op("sub", i, g.num(1))),
+		)},
+	}
+}
+
+// рекурсивные вызовы: size/100 раз fib(15)
+func benchCalls(g benchGen, size int) []Stmt {
+	n := g.ident("n")
+	fib := &FuncDecl{Name: g.ident("fib"), Params: []*Ident{n}, Body: g.block(
+		&IfStmt{Cond: n, Then: g.block(
+			&IfStmt{Cond: g.op("sub", n, g.num(1)), Then: g.block(
+				&ReturnStmt{Value: g.op("add", g.call("fib", g.op("sub", n, g.num(1))), g.call("fib", g.op("sub", n, g.num(2))))},
+			)},
+			&ReturnStmt{Value: g.num(1)},
+		)},
+		&ReturnStmt{Value: g.num(0)},
+	)}
+	i := g.ident("i")
+	return []Stmt{
+		fib,
+		g.assign("i", g.num(int64(size/100+1))),
+		&WhileStmt{Cond: i, Body: g.block(
+			g.assign("r", g.call("fib", g.num(15))),
+			g.assign("i", g.op("sub", i, g.num(1))),
+		)},
+	}
+}
+
+// время выполнения программы без разбора, для байт-кода - вместе с компиляцией;
+// переменные по окончании - для сверки обходом дерева и байт-кодом
+func benchRun(interp *Interpreter, prog *Program, treeWalk bool) (time.Duration, string, error) {
+	interp.Variables, interp.Functions, interp.Out = NewTrie(), make(map[string]*FuncDecl), io.Discard
+	var diags Diagnostics
+	start := time.Now()
+	interp.declareFunctions(prog.Stmts)
+	if treeWalk {
+		interp.executeBlock(prog.Stmts, &diags)
+	} else {
+		bc, err := interp.Compile(prog)
+		if err != nil {
+			return 0, "", err
+		}
+		interp.runBytecode(bc, &diags)
+	}
+	elapsed := time.Since(start)
+	var vars []string
+	for _, name := range interp.Variables.KeysWithPrefix("") {
+		vars = append(vars, name+"="+interp.DecimalToBase(interp.Variables.Search(name), 10))
+	}
+	return elapsed, strings.Join(vars, " "), diags.Err()
+}
+
+// Результат теста производительности
+type BenchResult struct {
+	Name     string
+	Lines    int
+	Tree     time.Duration
+	Bytecode time.Duration
+}
+
+// Ошибка теста производительности с текстом сгенерированной программы
+type BenchError struct {
+	Name    string
+	Program string
+	Err     error
+}
+
+func (e *BenchError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
+
+func (e *BenchError) Unwrap() error {
+	return e.Err
+}
+
+// Сравнение обхода дерева и байт-кода на сгенерированных программах; size - число инструкций,
+// 0 - defaultBenchSize
+func (interp *Interpreter) Benchmark(size int) ([]BenchResult, error) {
+	if _, ok := interp.Numeric.(fixedBackend); !ok {
+		return nil, errNotCompilable
+	}
+	if size == 0 {
+		size = defaultBenchSize
+	}
+	var results []BenchResult
+	for _, test := range benchCases {
+		// программа проходит через текст, чтобы разбор проверил её в диалекте настроек
+		program, err := NewPrinter(interp).Program(&Program{Stmts: test.build(benchGen{interp}, size)})
+		if err != nil {
+			return results, err
+		}
+		prog, err := interp.Parse(test.name, program)
+		if err != nil {
+			return results, &BenchError{test.name, program, err}
+		}
+		tree, treeVars, treeErr := benchRun(interp, prog, true)
+		vm, vmVars, vmErr := benchRun(interp, prog, false)
+		if err := firstError(treeErr, vmErr); err != nil {
+			return results, &BenchError{test.name, program, err}
+		}
+		if treeVars != vmVars {
+			return results, &BenchError{test.name, program, errors.New("результаты различаются")}
+		}
+		results = append(results, BenchResult{test.name, strings.Count(program, "\n"), tree, vm})
+	}
+	return results, nil
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
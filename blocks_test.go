@@ -0,0 +1,43 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// if/else if/else и while обходом дерева с переименованными ключевыми словами;
+// старое имя ключевого слова становится обычным идентификатором
+func TestRenamedBlocks(t *testing.T) {
+	d, err := ParseSettings("settings.txt", "left=\nif если\nelse иначе\nwhile пока\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := New(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp.In, interp.Out, interp.TreeWalk = strings.NewReader("3\n"), &out, true
+	program := `
+n = input();
+пока n {
+	если sub(n, 1) {
+		output(n);
+	} иначе если sub(n, 2) {
+		b = 1;
+	} иначе {
+		output(0);
+	}
+	n = sub(n, 1);
+}
+if = add(b, 1);
+output(if);
+`
+	if err := interp.Execute(program); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "n = 3\nn = 2\nif = 2\n"; got != want {
+		t.Errorf("вывод %q, ожидалось %q", got, want)
+	}
+}
@@ -0,0 +1,255 @@
+package interp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Точка останова по номеру инструкции или по строке исходника с необязательным условием
+type Breakpoint struct {
+	Stmt      int // номер инструкции; 0 - точка по строке
+	Line      int
+	Condition string
+}
+
+func (bp *Breakpoint) String() string {
+	where := fmt.Sprintf("инструкция %d", bp.Stmt)
+	if bp.Stmt == 0 {
+		where = fmt.Sprintf("строка %d", bp.Line)
+	}
+	if bp.Condition != "" {
+		return where + " if " + bp.Condition
+	}
+	return where
+}
+
+// Разбор "7", "line 12", "line:12", за которыми может идти "if <условие>"
+func ParseBreakpoint(spec string) (*Breakpoint, error) {
+	where, condition, conditional := strings.Cut(strings.TrimSpace(spec)+" ", " if ")
+	where = strings.TrimSpace(where)
+	bp := &Breakpoint{Condition: strings.TrimSpace(condition)}
+	if conditional && bp.Condition == "" {
+		return nil, fmt.Errorf("пустое условие точки останова %q", spec)
+	}
+
+	target := &bp.Stmt
+	if rest, ok := strings.CutPrefix(where, "line"); ok {
+		target = &bp.Line
+		where = strings.TrimLeft(rest, ": ")
+	}
+	n, err := strconv.Atoi(where)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("некорректная точка останова %q: ожидается номер инструкции или line <строка>", spec)
+	}
+	*target = n
+	return bp, nil
+}
+
+// Операции сравнения в условиях; длинные проверяются первыми
+var comparisons = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// разбиение условия на левую часть, сравнение и правую часть;
+// без сравнения условие истинно при ненулевом значении
+func (interp *Interpreter) splitCondition(condition string) (string, string, string) {
+	lx := NewLexer("", condition, interp.Commands)
+	for tok := lx.Next(); tok.Kind != TokEOF; tok = lx.Next() {
+		rest := condition[tok.Span.Start.Offset:]
+		for _, cmp := range comparisons {
+			// синоним операции, начинающийся со сравнения (например "><"), сравнением не считается
+			if strings.HasPrefix(rest, cmp) && (tok.Kind == TokIllegal || tok.Kind == TokAssign || tok.Text == cmp) {
+				return condition[:tok.Span.Start.Offset], cmp, rest[len(cmp):]
+			}
+		}
+		if tok.Kind == TokIllegal {
+			break
+		}
+	}
+	return condition, "", ""
+}
+
+// вычисление выражения условия через разбор и вычислитель интерпретатора.
+// Выражение не должно менять состояние программы: ввод, вывод и вызовы функций недопустимы,
+// а превышение ограничения - ошибка самого выражения, выполнение программы продолжается
+func (interp *Interpreter) evaluateText(text string) (Value, error) {
+	prog, err := interp.Parse("<условие>", text+";")
+	if err != nil {
+		return nil, err
+	}
+	if len(prog.Stmts) != 1 {
+		return nil, fmt.Errorf("условие должно быть одним выражением")
+	}
+	stmt, ok := prog.Stmts[0].(*ExprStmt)
+	if !ok {
+		return nil, fmt.Errorf("условие должно быть выражением")
+	}
+	var call error
+	walkExpr(stmt.X, func(e Expr) {
+		switch e := e.(type) {
+		case *CallExpr:
+			call = firstError(call, fmt.Errorf("%s недопустим в условии", e.Op.Text))
+		case *FuncCall:
+			call = firstError(call, fmt.Errorf("вызов функции %s недопустим в условии", e.Name.Text))
+		}
+	})
+	if call != nil {
+		return nil, call
+	}
+	stopErr := interp.stopErr
+	value, err := interp.EvaluateExpression(stmt.X)
+	if err == errStopped && interp.stopErr != stopErr {
+		err, interp.stopErr = interp.stopErr, stopErr
+	}
+	return value, err
+}
+
+// Значение условия точки останова
+func (interp *Interpreter) EvaluateCondition(condition string) (bool, error) {
+	left, cmp, right := interp.splitCondition(condition)
+	x, err := interp.evaluateText(left)
+	if err != nil {
+		return false, err
+	}
+	if cmp == "" {
+		return interp.Numeric.Compare(x, interp.Numeric.FromInt64(0)) != 0, nil
+	}
+	y, err := interp.evaluateText(right)
+	if err != nil {
+		return false, err
+	}
+	switch c := interp.Numeric.Compare(x, y); cmp {
+	case "==":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// проверка синтаксиса условия без вычисления
+func (interp *Interpreter) checkCondition(condition string) error {
+	left, cmp, right := interp.splitCondition(condition)
+	sides := []string{left}
+	if cmp != "" {
+		sides = append(sides, right)
+	}
+	for _, side := range sides {
+		if _, err := interp.Parse("<условие>", side+";"); err != nil {
+			return fmt.Errorf("ошибка в условии %q: %v", condition, err)
+		}
+	}
+	return nil
+}
+
+// Добавление точки останова; условие проверяется сразу на синтаксис
+func (interp *Interpreter) SetBreakpoint(bp *Breakpoint) error {
+	if bp.Condition != "" {
+		if err := interp.checkCondition(bp.Condition); err != nil {
+			return err
+		}
+	}
+	interp.ClearBreakpoint(bp)
+	interp.Breakpoints = append(interp.Breakpoints, bp)
+	return nil
+}
+
+// Удаление точки останова в том же месте; false если её не было
+func (interp *Interpreter) ClearBreakpoint(bp *Breakpoint) bool {
+	for i, existing := range interp.Breakpoints {
+		if existing.Stmt == bp.Stmt && existing.Line == bp.Line {
+			interp.Breakpoints = append(interp.Breakpoints[:i], interp.Breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// причина остановки перед инструкцией; "" - выполнение продолжается.
+// Остановка по наблюдению ("watch") проверяется после инструкции в Execute.
+// Ошибка вычисления условия (например, переменная ещё не объявлена) означает, что условие ложно
+func (interp *Interpreter) stopReason(stmt Stmt) string {
+	if interp.Stepping && len(interp.frames) <= interp.stepDepth {
+		interp.Stepping = false
+		return "step"
+	}
+	if stmt.HasBreakpoint() {
+		return "marker"
+	}
+	span := stmtHeader(stmt)
+	for _, bp := range interp.Breakpoints {
+		if bp.Stmt != 0 && bp.Stmt != stmt.Number() {
+			continue
+		}
+		if bp.Stmt == 0 && (bp.Line < span.Start.Line || bp.Line > span.End.Line) {
+			continue
+		}
+		if bp.Condition == "" {
+			return "breakpoint"
+		}
+		if hit, err := interp.EvaluateCondition(bp.Condition); err == nil && hit {
+			return "breakpoint"
+		}
+	}
+	return ""
+}
+
+// Текстовые команды отладчика, общие для меню и сценария:
+// break <место> [if <условие>], clear <место>, breaks, step, next, finish, stack, continue и команды наблюдения.
+// handled=false - команда не из этого набора; resume=true - продолжить выполнение
+func (interp *Interpreter) debuggerCommand(line string) (output []string, resume, handled bool, err error) {
+	name, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+	name = strings.ToLower(name)
+	if output, handled, err := interp.watchCommand(name, args); handled {
+		return output, false, true, err
+	}
+	switch name {
+	case "break", "b":
+		bp, err := ParseBreakpoint(args)
+		if err == nil {
+			err = interp.SetBreakpoint(bp)
+		}
+		if err != nil {
+			return nil, false, true, err
+		}
+		return []string{"Точка останова: " + bp.String()}, false, true, nil
+	case "clear":
+		bp, err := ParseBreakpoint(args)
+		if err != nil {
+			return nil, false, true, err
+		}
+		if !interp.ClearBreakpoint(bp) {
+			return nil, false, true, fmt.Errorf("точка останова %s не задана", bp)
+		}
+		return []string{"Точка останова удалена: " + bp.String()}, false, true, nil
+	case "breaks":
+		for _, bp := range interp.Breakpoints {
+			output = append(output, bp.String())
+		}
+		return output, false, true, nil
+	case "step", "s":
+		interp.Step("in")
+		return nil, true, true, nil
+	case "next", "n":
+		interp.Step("over")
+		return nil, true, true, nil
+	case "finish", "out":
+		interp.Step("out")
+		return nil, true, true, nil
+	case "stack", "bt":
+		span := Span{}
+		if interp.current != nil {
+			span = interp.current.Pos()
+		}
+		return interp.CallStack(span), false, true, nil
+	case "continue", "c":
+		return nil, true, true, nil
+	}
+	return nil, false, false, nil
+}
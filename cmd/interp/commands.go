@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"interp"
+)
+
+// команда translate --from <настройки> --to <настройки> <программа>
+func runTranslate(opts cliOptions) int {
+	if len(opts.positional) != 1 || opts.from == "" || opts.to == "" {
+		fmt.Println(usage)
+		return 1
+	}
+	programFile := opts.positional[0]
+	program, err := readFile(programFile)
+	if err != nil {
+		fmt.Println("Error reading program file:", err)
+		return 1
+	}
+	from, err := newInterpreterFromOptions(opts.from, opts)
+	if err != nil {
+		reportSettingsError(err, opts.from)
+		return 1
+	}
+	to, err := newInterpreterFromOptions(opts.to, opts)
+	if err != nil {
+		reportSettingsError(err, opts.to)
+		return 1
+	}
+	from.ProgramFile, to.ProgramFile = programFile, programFile
+
+	translated, err := interp.Translate(from, to, programFile, program)
+	if err != nil {
+		reportError(err, program)
+		return 1
+	}
+	if !opts.noVerify {
+		if err := interp.VerifyTranslation(from, to, program, translated); err != nil {
+			fmt.Fprintln(os.Stderr, "Перевод не эквивалентен исходной программе:", err)
+			return 1
+		}
+	}
+	return writeResult(opts, translated)
+}
+
+// команда fmt: печать в stdout, --write - перезапись файлов,
+// --check - список неотформатированных файлов и код 1
+func runFormat(opts cliOptions) int {
+	if len(opts.positional) < 2 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile, files := opts.positional[0], opts.positional[1:]
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+
+	status := 0
+	for _, file := range files {
+		program, err := readFile(file)
+		if err != nil {
+			fmt.Println("Error reading program file:", err)
+			status = 1
+			continue
+		}
+		formatted, err := interpreter.Format(file, program)
+		if err != nil {
+			reportError(err, program)
+			status = 1
+			continue
+		}
+		switch {
+		case opts.check:
+			if formatted != program {
+				fmt.Println(file)
+				status = 1
+			}
+		case opts.write:
+			if formatted != program {
+				if err := os.WriteFile(file, []byte(formatted), 0644); err != nil {
+					fmt.Println(err)
+					status = 1
+				}
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	return status
+}
+
+// команда lint: диагностики всех файлов в stdout, код 1 если что-то найдено
+func runLint(opts cliOptions) int {
+	if len(opts.positional) < 2 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile, files := opts.positional[0], opts.positional[1:]
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+	status := 0
+	for _, file := range files {
+		program, err := readFile(file)
+		if err != nil {
+			fmt.Println("Error reading program file:", err)
+			status = 1
+			continue
+		}
+		if diags := interpreter.Lint(file, program); len(diags) > 0 {
+			fmt.Print(diags.Render(program))
+			status = 1
+		}
+	}
+	return status
+}
+
+// команда optimize: печать оптимизированной программы, итог - в stderr
+func runOptimize(opts cliOptions) int {
+	if len(opts.positional) != 2 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile, programFile := opts.positional[0], opts.positional[1]
+	program, err := readFile(programFile)
+	if err != nil {
+		fmt.Println("Error reading program file:", err)
+		return 1
+	}
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+	prog, err := interpreter.Parse(programFile, program)
+	if err != nil {
+		reportError(err, program)
+		return 1
+	}
+	optimized, result := interpreter.OptimizeProgram(prog)
+	text, err := interp.NewPrinter(interpreter).Program(optimized)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "свёрнуто выражений: %d, удалено присваиваний: %d\n", result.Folded, result.Removed)
+	return writeResult(opts, text)
+}
+
+// текст программы в stdout или в файл --out
+func writeResult(opts cliOptions, text string) int {
+	if opts.out == "" {
+		fmt.Print(text)
+		return 0
+	}
+	if err := os.WriteFile(opts.out, []byte(text), 0644); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// команда bench: сравнение обхода дерева и байт-кода на сгенерированных программах
+func runBench(opts cliOptions) int {
+	if len(opts.positional) != 1 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile := opts.positional[0]
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+	results, err := interpreter.Benchmark(opts.size)
+	var benchErr *interp.BenchError
+	if err != nil && !errors.As(err, &benchErr) {
+		fmt.Println(err)
+		return 1
+	}
+
+	fmt.Printf("%-12s %8s %14s %14s %10s\n", "тест", "строк", "дерево", "байт-код", "ускорение")
+	for _, result := range results {
+		fmt.Printf("%-12s %8d %14s %14s %9.1fx\n", result.Name, result.Lines,
+			result.Tree.Round(time.Microsecond), result.Bytecode.Round(time.Microsecond), float64(result.Tree)/float64(result.Bytecode))
+	}
+	if benchErr != nil {
+		fmt.Println(benchErr.Name + ":")
+		reportError(benchErr.Err, benchErr.Program)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fmt --check возвращает 1 для неотформатированного файла и не меняет его, --write исправляет
+func TestFormatCheck(t *testing.T) {
+	dir := t.TempDir()
+	settings := filepath.Join(dir, "settings.txt")
+	program := filepath.Join(dir, "program.txt")
+	if err := os.WriteFile(settings, []byte("left=\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := "a=5;\noutput( a );\n"
+	if err := os.WriteFile(program, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	format := func(mode string) int {
+		opts, err := parseOptions([]string{settings, program, mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runFormat(opts)
+	}
+
+	if status := format("--check"); status != 1 {
+		t.Errorf("--check для неотформатированного файла: код %d", status)
+	}
+	if data, _ := os.ReadFile(program); string(data) != source {
+		t.Errorf("--check изменил файл: %q", data)
+	}
+	if status := format("--write"); status != 0 {
+		t.Errorf("--write: код %d", status)
+	}
+	if data, _ := os.ReadFile(program); string(data) != "a = 5;\noutput(a);\n" {
+		t.Errorf("--write: %q", data)
+	}
+	if status := format("--check"); status != 0 {
+		t.Errorf("--check для отформатированного файла: код %d", status)
+	}
+}
@@ -0,0 +1,443 @@
+// Интерпретатор из командной строки: запуск программ, REPL, серверы LSP и DAP
+// и служебные команды поверх пакета interp
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"interp"
+)
+
+// параметры командной строки
+type cliOptions struct {
+	interp.Options
+	positional []string
+	format     string
+	out        string
+	// источник ввода input: файл или значения из командной строки, и файл для output
+	inputs      string
+	inputValues []string
+	output      string
+	// сценарий отладчика и файл протокола (по умолчанию stderr)
+	debugScript     string
+	debugTranscript string
+	breaks          []string // --break, можно указать несколько раз
+	dap             string   // "stdio" или TCP-порт сервера DAP
+	// диалекты команды translate и отказ от проверки перевода
+	from     string
+	to       string
+	noVerify bool
+	// режимы команды fmt
+	check bool
+	write bool
+	size  int // размер программ команды bench, 0 - по умолчанию
+}
+
+// параметры со значением, которое можно передать следующим аргументом: --from a.txt
+var valueOptions = map[string]bool{"from": true, "to": true, "out": true, "format": true, "numeric": true, "inputs": true, "input": true, "output": true}
+
+// числовые ограничения выполнения
+var limitOptions = map[string]func(l *interp.Limits, n int64){
+	"max-steps":   func(l *interp.Limits, n int64) { l.MaxSteps = n },
+	"max-vars":    func(l *interp.Limits, n int64) { l.MaxVariables = int(n) },
+	"max-literal": func(l *interp.Limits, n int64) { l.MaxLiteralLen = int(n) },
+	"max-nesting": func(l *interp.Limits, n int64) { l.MaxNesting = int(n) },
+	"max-bits":    func(l *interp.Limits, n int64) { l.MaxValueBits = int(n) },
+}
+
+func parseOptions(args []string) (cliOptions, error) {
+	opts := cliOptions{format: "txt"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue && strings.HasPrefix(arg, "--") && valueOptions[name] && i+1 < len(args) {
+			i++
+			value, hasValue = args[i], true
+		}
+		switch {
+		case arg == "--debug" || arg == "-d" || arg == "/debug":
+			opts.Debug = true
+		case arg == "--dap":
+			opts.dap = "stdio"
+		case hasValue && name == "dap":
+			if _, err := strconv.ParseUint(value, 10, 16); err != nil {
+				return opts, fmt.Errorf("--dap: некорректный порт %s", value)
+			}
+			opts.dap = value
+		case arg == "--keep-going" || arg == "-k":
+			opts.KeepGoing = true
+		case arg == "--optimize" || arg == "-O":
+			opts.Optimize = true
+		case arg == "--quiet" || arg == "-q":
+			opts.Quiet = true
+		case hasValue && name == "inputs":
+			opts.inputs = value
+		case hasValue && name == "input":
+			opts.inputValues = append(opts.inputValues, strings.Split(value, ",")...)
+		case hasValue && name == "output":
+			opts.output = value
+		case hasValue && strings.HasPrefix(name, "base-"):
+			base, err := strconv.Atoi(value)
+			if err == nil {
+				err = interp.CheckBase(name, base)
+			}
+			if err != nil {
+				return opts, fmt.Errorf("%s: некорректное основание %s", name, value)
+			}
+			switch name {
+			case "base-assign":
+				opts.BaseAssign = base
+			case "base-input":
+				opts.BaseInput = base
+			case "base-output":
+				opts.BaseOutput = base
+			default:
+				return opts, fmt.Errorf("неизвестный параметр %s", arg)
+			}
+		case hasValue && name == "numeric":
+			opts.Numeric = value
+		case hasValue && name == "max-depth":
+			depth, err := strconv.Atoi(value)
+			if err != nil || depth < 1 {
+				return opts, fmt.Errorf("--max-depth: некорректная глубина %s", value)
+			}
+			opts.MaxCallDepth = depth
+		case hasValue && name == "size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size < 1 {
+				return opts, fmt.Errorf("--size: некорректный размер %s", value)
+			}
+			opts.size = size
+		case hasValue && name == "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return opts, fmt.Errorf("--timeout: некорректное время %s", value)
+			}
+			opts.Limits.Timeout = timeout
+		case hasValue && limitOptions[name] != nil:
+			limit, err := strconv.ParseInt(value, 10, 32)
+			if err != nil || limit < 1 {
+				return opts, fmt.Errorf("--%s: некорректное ограничение %s", name, value)
+			}
+			limitOptions[name](&opts.Limits, limit)
+		case hasValue && name == "format":
+			opts.format = value
+		case hasValue && name == "out":
+			opts.out = value
+		case hasValue && name == "from":
+			opts.from = value
+		case hasValue && name == "to":
+			opts.to = value
+		case arg == "--no-verify":
+			opts.noVerify = true
+		case arg == "--check":
+			opts.check = true
+		case arg == "--write" || arg == "-w":
+			opts.write = true
+		case hasValue && name == "debug-script":
+			opts.debugScript = value
+			opts.Debug = true
+		case hasValue && name == "debug-transcript":
+			opts.debugTranscript = value
+		case hasValue && name == "break":
+			opts.breaks = append(opts.breaks, value)
+			opts.Debug = true
+		case strings.HasPrefix(arg, "-"):
+			return opts, fmt.Errorf("неизвестный параметр %s", arg)
+		default:
+			opts.positional = append(opts.positional, arg)
+		}
+	}
+	if opts.inputs != "" && opts.inputValues != nil {
+		return opts, fmt.Errorf("--inputs и --input нельзя указывать вместе")
+	}
+	return opts, nil
+}
+
+// интерпретатор по параметрам командной строки с вводом-выводом через консоль;
+// имя файла настроек запоминается в last_settings.txt
+func newInterpreterFromOptions(settingsFile string, opts cliOptions) (*interp.Interpreter, error) {
+	interpreter, err := interp.NewInterpreterWithOptions(settingsFile, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	interpreter.In, interpreter.Out = os.Stdin, os.Stdout
+	if !opts.Quiet {
+		interpreter.Prompt = os.Stdout
+	}
+	interpreter.SaveLastSettings()
+	return interpreter, nil
+}
+
+// ввод input из файла --inputs или значений --input, вывод output в файл --output;
+// closeFiles закрывает открытые файлы. Значения из --inputs и --input вводятся без приглашений:
+// их некому читать, а в выводе они мешали бы. Ввод через канал в stdin приглашения
+// сохраняет, как и раньше, для него их отключает --quiet
+func redirectIO(interpreter *interp.Interpreter, opts cliOptions) (closeFiles func(), err error) {
+	var files []*os.File
+	closeFiles = func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+	switch {
+	case opts.inputs != "":
+		file, err := os.Open(opts.inputs)
+		if err != nil {
+			return closeFiles, err
+		}
+		files = append(files, file)
+		interpreter.In, interpreter.Prompt = bufio.NewReader(file), nil
+	case opts.inputValues != nil:
+		interpreter.In, interpreter.Prompt = strings.NewReader(strings.Join(opts.inputValues, "\n")+"\n"), nil
+	}
+	if opts.output != "" {
+		file, err := os.Create(opts.output)
+		if err != nil {
+			return closeFiles, err
+		}
+		files = append(files, file)
+		interpreter.Out = file
+	}
+	return closeFiles, nil
+}
+
+// вывод ошибки в stderr, диагностики - с исходными строками
+func reportError(err error, src string) {
+	var diags interp.Diagnostics
+	if errors.As(err, &diags) {
+		fmt.Fprint(os.Stderr, diags.Render(src))
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// вывод ошибки загрузки настроек
+func reportSettingsError(err error, settingsFile string) {
+	settings, _ := readFile(settingsFile)
+	reportError(err, settings)
+}
+
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+const usage = `Usage: interp [run] <settings_file> <program_file> [--debug|-d|/debug] [--break=<stmt>|line:<line>[ if <cond>]]... [--debug-script=<file> [--debug-transcript=<file>]] [--keep-going|-k] [--optimize|-O] [--inputs=<file>|--input=<value>[,<value>]...] [--output=<file>] [--quiet|-q] [--numeric=32|64|big] [--max-depth=<calls>] [--max-steps=<n>] [--timeout=<duration>] [--max-vars=<n>] [--max-literal=<digits>] [--max-nesting=<levels>] [--max-bits=<n>] [base-assign=<value>] [base-input=<value>] [base-output=<value>]
+       interp [<settings_file>] --dap[=<port>]
+       interp lsp [<settings_file>]
+       interp repl <settings_file> [--numeric=...] [base-...=<value>]
+       interp translate --from <settings_file> --to <settings_file> <program_file> [--out=<file>] [--no-verify]
+       interp fmt <settings_file> <program_file>... [--check|--write|-w]
+       interp lint <settings_file> <program_file>... [--keep-going|-k] [--numeric=...] [base-...=<value>]
+       interp optimize <settings_file> <program_file> [--out=<file>] [--numeric=...] [base-...=<value>]
+       interp bench <settings_file> [--size=<statements>] [--numeric=32|64] [base-...=<value>]
+       interp dump-settings <settings_file> [--format=txt|json|toml] [--out=<file>] [--numeric=...] [base-...=<value>]`
+
+func main() {
+	args := os.Args[1:]
+	command := "run"
+	if len(args) > 0 && (args[0] == "run" || args[0] == "repl" || args[0] == "lsp" || args[0] == "translate" || args[0] == "fmt" || args[0] == "lint" || args[0] == "bench" || args[0] == "optimize" || args[0] == "dump-settings") {
+		command, args = args[0], args[1:]
+	}
+
+	opts, err := parseOptions(args)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if opts.dap != "" {
+		os.Exit(serveDAP(opts))
+	}
+
+	switch command {
+	case "repl":
+		os.Exit(runRepl(opts))
+	case "lsp":
+		os.Exit(serveLSP(opts))
+	case "translate":
+		os.Exit(runTranslate(opts))
+	case "fmt":
+		os.Exit(runFormat(opts))
+	case "lint":
+		os.Exit(runLint(opts))
+	case "bench":
+		os.Exit(runBench(opts))
+	case "optimize":
+		os.Exit(runOptimize(opts))
+	case "dump-settings":
+		os.Exit(dumpSettings(opts))
+	default:
+		os.Exit(runProgram(opts))
+	}
+}
+
+// выполнение программы из файла
+func runProgram(opts cliOptions) int {
+	if len(opts.positional) != 2 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile, programFile := opts.positional[0], opts.positional[1]
+
+	// Read program file
+	program, err := readFile(programFile)
+	if err != nil {
+		fmt.Println("Error reading program file:", err)
+		return 1
+	}
+
+	// Create interpreter instance with settings and execute program
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+	interpreter.ProgramFile = programFile
+	closeFiles, err := redirectIO(interpreter, opts)
+	defer closeFiles()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if opts.Debug {
+		// меню отладчика остаётся на консоли, даже если ввод и вывод программы перенаправлены
+		interpreter.Debugger = &interp.ConsoleDebugger{In: os.Stdin, Out: os.Stdout}
+	}
+	for _, spec := range opts.breaks {
+		bp, err := interp.ParseBreakpoint(spec)
+		if err == nil {
+			err = interpreter.SetBreakpoint(bp)
+		}
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	if opts.debugScript != "" {
+		transcript := os.Stderr
+		if opts.debugTranscript != "" {
+			file, err := os.Create(opts.debugTranscript)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			defer file.Close()
+			transcript = file
+		}
+		debugger, err := interp.NewScriptDebugger(opts.debugScript, transcript)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		interpreter.Debugger = debugger
+	}
+	if err := interpreter.Execute(program); err != nil {
+		reportError(err, program)
+		return 1
+	}
+	return 0
+}
+
+// интерактивный режим
+func runRepl(opts cliOptions) int {
+	if len(opts.positional) != 1 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile := opts.positional[0]
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+	if err := interp.NewConsoleRepl(interpreter).Run(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// запись действующих настроек в txt, json или toml
+func dumpSettings(opts cliOptions) int {
+	if len(opts.positional) != 1 {
+		fmt.Println(usage)
+		return 1
+	}
+	settingsFile := opts.positional[0]
+	interpreter, err := newInterpreterFromOptions(settingsFile, opts)
+	if err != nil {
+		reportSettingsError(err, settingsFile)
+		return 1
+	}
+
+	out := os.Stdout
+	if opts.out != "" {
+		file, err := os.Create(opts.out)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := interpreter.CurrentDialect().Write(out, opts.format); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// файл настроек для серверов: первый позиционный параметр, если он есть
+func serverSettings(opts cliOptions) string {
+	if len(opts.positional) > 0 {
+		return opts.positional[0]
+	}
+	return ""
+}
+
+// запуск сервера LSP через stdin/stdout
+func serveLSP(opts cliOptions) int {
+	if err := interp.NewLSPServer(os.Stdin, os.Stdout, serverSettings(opts), opts.Options).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// запуск сервера DAP: --dap - через stdin/stdout, --dap=<порт> - TCP на 127.0.0.1
+func serveDAP(opts cliOptions) int {
+	if opts.dap == "stdio" {
+		if err := interp.NewDAPServer(os.Stdin, os.Stdout, serverSettings(opts), opts.Options).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", opts.dap))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer listener.Close()
+	fmt.Fprintf(os.Stderr, "DAP: ожидание подключения на %s\n", listener.Addr())
+	conn, err := listener.Accept()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	if err := interp.NewDAPServer(conn, conn, serverSettings(opts), opts.Options).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"interp"
+)
+
+// --input, --inputs, --output и -q: ввод из списка или файла без приглашений, вывод в файл
+func TestRedirectIO(t *testing.T) {
+	dir := t.TempDir()
+	inputs := filepath.Join(dir, "inputs.txt")
+	if err := os.WriteFile(inputs, []byte("4\n5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	program := "a = input();\nb = input();\noutput(mult(a, b));\n"
+	for _, args := range [][]string{
+		{"--input=4,5"},
+		{"--input", "4", "--input=5"},
+		{"--inputs", inputs},
+	} {
+		output := filepath.Join(dir, "out.txt")
+		opts, err := parseOptions(append(args, "--output="+output))
+		if err != nil {
+			t.Fatal(err)
+		}
+		interpreter, err := interp.New(interp.NewDialect())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prompt bytes.Buffer
+		interpreter.Prompt = &prompt
+		closeFiles, err := redirectIO(interpreter, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = interpreter.Execute(program)
+		closeFiles()
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if got, _ := os.ReadFile(output); string(got) != "mult(a, b) = 20\n" {
+			t.Errorf("%v: вывод %q", args, got)
+		}
+		if prompt.Len() != 0 {
+			t.Errorf("%v: приглашения при вводе не с консоли: %q", args, prompt.String())
+		}
+	}
+	if _, err := parseOptions([]string{"--input=1", "--inputs", inputs}); err == nil {
+		t.Error("--input и --inputs вместе не дали ошибки")
+	}
+	if opts, err := parseOptions([]string{"-q"}); err != nil || !opts.Quiet {
+		t.Errorf("-q: Quiet = %v, %v", opts.Quiet, err)
+	}
+}
@@ -0,0 +1,578 @@
+package interp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Сервер Debug Adapter Protocol: редактор управляет выполнением программы через
+// запросы launch, setBreakpoints, continue/next/stepIn, stackTrace, scopes, variables,
+// setVariable и evaluate. Программа выполняется обычным Execute в отдельной горутине,
+// а сервер подключается к нему как Debugger и ждёт команды продолжения на каждой остановке.
+type DAPServer struct {
+	in           *bufio.Reader
+	out          io.Writer
+	settingsFile string // настройки, если launch их не указывает
+	opts         Options
+
+	writeMu sync.Mutex // запись сообщений из обеих горутин
+	seq     int
+
+	interp      *Interpreter
+	programFile string
+	program     string
+	prog        *Program
+	stopOnEntry bool
+	noDebug     bool
+	launched    bool
+	configured  bool
+
+	// точки останова по строкам до запуска, по абсолютному пути файла
+	lineBreaks map[string][]*Breakpoint
+
+	stateMu sync.Mutex
+	running bool
+	stopped Stmt // инструкция, на которой стоит выполнение; nil - выполняется или не запущено
+	pending []*Breakpoint
+	changed bool // pending нужно применить на следующей остановке
+	resume  chan string
+}
+
+// Запрос клиента
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+const (
+	dapThreadID      = 1
+	dapVariablesRef  = 1
+	dapWatchesRef    = 2
+	dapGlobalsRef    = 3
+	errProgramActive = "программа выполняется"
+)
+
+//создание; settingsFile и параметры используются при launch
+func NewDAPServer(in io.Reader, out io.Writer, settingsFile string, opts Options) *DAPServer {
+	return &DAPServer{
+		in:           bufio.NewReader(in),
+		out:          out,
+		settingsFile: settingsFile,
+		opts:         opts,
+		lineBreaks:   make(map[string][]*Breakpoint),
+		resume:       make(chan string),
+	}
+}
+
+func (s *DAPServer) read() (*dapRequest, error) {
+	body, err := readMessage(s.in)
+	if err != nil {
+		return nil, err
+	}
+	var req dapRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (s *DAPServer) write(msg interface{}) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *dapResponse:
+		m.Seq = s.seq
+	case *dapEvent:
+		m.Seq = s.seq
+	}
+	body, _ := json.Marshal(msg)
+	writeMessage(s.out, body)
+}
+
+func (s *DAPServer) respond(req *dapRequest, body interface{}) {
+	s.write(&dapResponse{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *DAPServer) fail(req *dapRequest, err error) {
+	s.write(&dapResponse{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: err.Error()})
+}
+
+func (s *DAPServer) event(name string, body interface{}) {
+	s.write(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+// вывод программы как события output
+type dapOutput struct {
+	server   *DAPServer
+	category string
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.server.event("output", map[string]interface{}{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
+
+// Обработка запросов до disconnect или конца ввода
+func (s *DAPServer) Serve() error {
+	for {
+		req, err := s.read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if err := s.handle(req); err != nil {
+			s.fail(req, err)
+		}
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			return nil
+		}
+	}
+}
+
+func (s *DAPServer) handle(req *dapRequest) error {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsSetVariable":              true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		if err := s.launch(req.Arguments); err != nil {
+			return err
+		}
+		s.respond(req, nil)
+		s.launched = true
+		s.start()
+	case "setBreakpoints":
+		return s.setBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.respond(req, map[string]interface{}{"breakpoints": []interface{}{}})
+	case "configurationDone":
+		s.respond(req, nil)
+		s.configured = true
+		s.start()
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThreadID, "name": "main"}},
+		})
+	case "stackTrace":
+		return s.stackTrace(req)
+	case "scopes":
+		scopes := []map[string]interface{}{
+			{"name": "Переменные", "variablesReference": dapVariablesRef, "expensive": false},
+			{"name": "Наблюдения", "variablesReference": dapWatchesRef, "expensive": false},
+		}
+		if _, err := s.current(); err == nil && len(s.interp.frames) > 0 {
+			scopes = append(scopes, map[string]interface{}{"name": "Глобальные", "variablesReference": dapGlobalsRef, "expensive": false})
+		}
+		s.respond(req, map[string]interface{}{"scopes": scopes})
+	case "variables":
+		return s.variables(req)
+	case "setVariable":
+		return s.setVariable(req)
+	case "evaluate":
+		return s.evaluate(req)
+	case "continue":
+		s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+		s.proceed("continue")
+	case "next", "stepIn", "stepOut":
+		s.respond(req, nil)
+		s.proceed(map[string]string{"next": "over", "stepIn": "in", "stepOut": "out"}[req.Command])
+	case "pause":
+		return errors.New("приостановка выполняющейся программы не поддерживается, используйте точки останова")
+	case "disconnect", "terminate":
+		s.proceed("quit")
+		s.respond(req, nil)
+	default:
+		return fmt.Errorf("неизвестный запрос %s", req.Command)
+	}
+	return nil
+}
+
+// аргументы launch: program, settings, stopOnEntry, noDebug, inputs (значения для input)
+func (s *DAPServer) launch(raw json.RawMessage) error {
+	var args struct {
+		Program     string   `json:"program"`
+		Settings    string   `json:"settings"`
+		StopOnEntry bool     `json:"stopOnEntry"`
+		NoDebug     bool     `json:"noDebug"`
+		Inputs      []string `json:"inputs"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	settingsFile := args.Settings
+	if settingsFile == "" {
+		settingsFile = s.settingsFile
+	}
+	if args.Program == "" || settingsFile == "" {
+		return errors.New("в launch нужно указать program и settings")
+	}
+
+	interp, err := NewInterpreterWithOptions(settingsFile, s.opts)
+	if err != nil {
+		settings, _ := readFile(settingsFile)
+		var diags Diagnostics
+		if errors.As(err, &diags) {
+			return errors.New(diags.Render(settings))
+		}
+		return err
+	}
+	program, err := readFile(args.Program)
+	if err != nil {
+		return err
+	}
+	s.programFile, _ = filepath.Abs(args.Program)
+	s.program = program
+	s.prog, _ = interp.Parse(s.programFile, program)
+	s.interp = interp
+	s.stopOnEntry = args.StopOnEntry
+	s.noDebug = args.NoDebug
+
+	interp.ProgramFile = s.programFile
+	interp.Debug = !args.NoDebug
+	interp.Debugger = s
+	interp.Out = dapOutput{server: s, category: "stdout"}
+	if !s.opts.Quiet {
+		interp.Prompt = interp.Out
+	}
+	interp.In = strings.NewReader(strings.Join(args.Inputs, "\n") + "\n")
+	interp.Breakpoints = append(interp.Breakpoints, s.lineBreaks[s.programFile]...)
+	return nil
+}
+
+// запуск после launch и configurationDone
+func (s *DAPServer) start() {
+	if !s.launched || !s.configured {
+		return
+	}
+	s.stateMu.Lock()
+	if s.running {
+		s.stateMu.Unlock()
+		return
+	}
+	s.running = true
+	s.stateMu.Unlock()
+
+	if s.stopOnEntry && !s.noDebug {
+		s.interp.Step("in")
+	}
+	go func() {
+		exitCode := 0
+		if err := s.interp.Execute(s.program); err != nil {
+			exitCode = 1
+			var diags Diagnostics
+			text := err.Error() + "\n"
+			if errors.As(err, &diags) {
+				text = diags.Render(s.program)
+			}
+			s.event("output", map[string]interface{}{"category": "stderr", "output": text})
+		}
+		s.stateMu.Lock()
+		s.running = false
+		s.stateMu.Unlock()
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// Остановка выполнения: событие stopped и ожидание continue, next или disconnect
+func (s *DAPServer) Break(interp *Interpreter, stmt Stmt, reason string) bool {
+	s.stateMu.Lock()
+	if s.changed {
+		interp.Breakpoints, s.changed = s.pending, false
+	}
+	s.stopped = stmt
+	s.stateMu.Unlock()
+
+	body := map[string]interface{}{"threadId": dapThreadID, "allThreadsStopped": true}
+	switch reason {
+	case "step":
+		body["reason"] = "step"
+		if s.stopOnEntry {
+			body["reason"], s.stopOnEntry = "entry", false
+		}
+	case "watch":
+		body["reason"] = "data breakpoint"
+		body["description"] = strings.Join(interp.watchChanges(), "; ")
+	default:
+		body["reason"] = "breakpoint"
+	}
+	s.event("stopped", body)
+
+	switch action := <-s.resume; action {
+	case "in", "over", "out":
+		interp.Step(action)
+	case "quit":
+		return false
+	}
+	return true
+}
+
+// продолжение с остановки; вне остановки игнорируется
+func (s *DAPServer) proceed(action string) {
+	s.stateMu.Lock()
+	stopped := s.stopped != nil
+	s.stopped = nil
+	s.stateMu.Unlock()
+	if stopped {
+		s.resume <- action
+	}
+}
+
+// текущая остановка; ошибка, если программа выполняется или не запущена
+func (s *DAPServer) current() (Stmt, error) {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	if s.stopped == nil {
+		return nil, errors.New(errProgramActive)
+	}
+	return s.stopped, nil
+}
+
+func (s *DAPServer) setBreakpoints(req *dapRequest) error {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	path, _ := filepath.Abs(args.Source.Path)
+
+	var breaks []*Breakpoint
+	var result []map[string]interface{}
+	for _, b := range args.Breakpoints {
+		bp := &Breakpoint{Line: b.Line, Condition: strings.TrimSpace(b.Condition)}
+		verified, message := s.verify(path, bp)
+		if verified {
+			breaks = append(breaks, bp)
+		}
+		entry := map[string]interface{}{"verified": verified, "line": b.Line}
+		if message != "" {
+			entry["message"] = message
+		}
+		result = append(result, entry)
+	}
+	s.lineBreaks[path] = breaks
+
+	if s.interp != nil && path == s.programFile {
+		// точки по инструкциям не из редактора; по строкам заменяются целиком
+		var all []*Breakpoint
+		for _, bp := range s.interp.Breakpoints {
+			if bp.Stmt != 0 {
+				all = append(all, bp)
+			}
+		}
+		all = append(all, breaks...)
+		s.stateMu.Lock()
+		if s.running && s.stopped == nil {
+			s.pending, s.changed = all, true
+		} else {
+			s.interp.Breakpoints = all
+		}
+		s.stateMu.Unlock()
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+	return nil
+}
+
+// проверка точки: на строке есть инструкция, условие разбирается
+func (s *DAPServer) verify(path string, bp *Breakpoint) (bool, string) {
+	if s.interp == nil || path != s.programFile {
+		return true, ""
+	}
+	if bp.Condition != "" {
+		if err := s.interp.checkCondition(bp.Condition); err != nil {
+			return false, err.Error()
+		}
+	}
+	if s.prog == nil {
+		return true, ""
+	}
+	verified := false
+	walkStmts(s.prog.Stmts, func(stmt Stmt) {
+		if span := stmtHeader(stmt); span.Start.Line <= bp.Line && bp.Line <= span.End.Line {
+			verified = true
+		}
+	})
+	if !verified {
+		return false, "на строке нет инструкции"
+	}
+	return true, ""
+}
+
+func (s *DAPServer) stackTrace(req *dapRequest) error {
+	stmt, err := s.current()
+	if err != nil {
+		s.respond(req, map[string]interface{}{"stackFrames": []interface{}{}, "totalFrames": 0})
+		return nil
+	}
+	// кадры от выполняющейся функции к программе; место кадра вызывающего - вызов
+	var frames []map[string]interface{}
+	span, name := stmt.Pos(), fmt.Sprintf("инструкция %d", stmt.Number())
+	for i := len(s.interp.frames); i >= 0; i-- {
+		if i > 0 {
+			name = s.interp.frames[i-1].Func.Name.Text
+		} else if len(s.interp.frames) > 0 {
+			name = "<программа>"
+		}
+		frames = append(frames, map[string]interface{}{
+			"id":     len(frames) + 1,
+			"name":   name,
+			"source": map[string]interface{}{"name": filepath.Base(s.programFile), "path": s.programFile},
+			"line":   span.Start.Line, "column": span.Start.Col,
+			"endLine": span.End.Line, "endColumn": span.End.Col,
+		})
+		if i > 0 {
+			span = s.interp.frames[i-1].Call
+		}
+	}
+	s.respond(req, map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)})
+	return nil
+}
+
+// переменные текущей области видимости, глобальные или наблюдаемые выражения
+func (s *DAPServer) variables(req *dapRequest) error {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	vars := []map[string]interface{}{}
+	if _, err := s.current(); err == nil {
+		switch args.VariablesReference {
+		case dapVariablesRef, dapGlobalsRef:
+			scope := s.interp.Variables
+			if args.VariablesReference == dapGlobalsRef {
+				scope = s.interp.Globals()
+			}
+			names := scope.ObtainAll()
+			sort.Strings(names)
+			for _, name := range names {
+				value := scope.Search(name)
+				vars = append(vars, map[string]interface{}{
+					"name":               name,
+					"value":              s.interp.DecimalToBase(value, 10),
+					"type":               s.interp.Numeric.Name(),
+					"variablesReference": 0,
+				})
+			}
+		case dapWatchesRef:
+			for _, w := range s.interp.Watches {
+				vars = append(vars, map[string]interface{}{"name": w.Expr, "value": w.Value, "variablesReference": 0})
+			}
+		}
+	}
+	s.respond(req, map[string]interface{}{"variables": vars})
+	return nil
+}
+
+// новое значение в десятичной записи записывается в область, которую показывает клиент:
+// локальные переменные текущей функции или глобальные. Новые переменные не создаются
+func (s *DAPServer) setVariable(req *dapRequest) error {
+	var args struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	if _, err := s.current(); err != nil {
+		return err
+	}
+	scope := s.interp.Variables
+	switch args.VariablesReference {
+	case dapVariablesRef:
+	case dapGlobalsRef:
+		scope = s.interp.Globals()
+	default:
+		return errors.New("изменять можно только переменные")
+	}
+	if scope.Search(args.Name) == nil {
+		return fmt.Errorf("переменная %s не объявлена", args.Name)
+	}
+	value, err := s.interp.Numeric.Parse(strings.TrimSpace(args.Value), 10)
+	if err != nil {
+		return fmt.Errorf("некорректное значение %q: %v", args.Value, err)
+	}
+	// запись с историей идёт в Variables, поэтому глобальная область подставляется на время
+	outer := s.interp.Variables
+	s.interp.Variables = scope
+	s.interp.setVariable(args.Name, value, nil)
+	s.interp.Variables = outer
+	s.respond(req, map[string]interface{}{"value": s.interp.DecimalToBase(value, 10)})
+	return nil
+}
+
+// выражение языка; в консоли отладки также команды отладчика (watch, break, history...)
+func (s *DAPServer) evaluate(req *dapRequest) error {
+	var args struct {
+		Expression string `json:"expression"`
+		Context    string `json:"context"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		return err
+	}
+	if _, err := s.current(); err != nil {
+		return err
+	}
+	if args.Context == "repl" {
+		output, resume, handled, err := s.interp.debuggerCommand(args.Expression)
+		if handled && !resume {
+			if err != nil {
+				return err
+			}
+			s.respond(req, map[string]interface{}{"result": strings.Join(output, "\n"), "variablesReference": 0})
+			return nil
+		}
+	}
+	value, err := s.interp.evaluateText(args.Expression)
+	if err != nil {
+		return err
+	}
+	s.respond(req, map[string]interface{}{"result": s.interp.DecimalToBase(value, 10), "variablesReference": 0})
+	return nil
+}
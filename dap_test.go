@@ -0,0 +1,55 @@
+package interp
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// setVariable меняет переменную в области, выбранной клиентом, и не создаёт новых
+func TestDAPSetVariable(t *testing.T) {
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	globals, locals := NewTrie(), NewTrie()
+	globals.Insert("x", interp.Numeric.FromInt64(1))
+	globals.Insert("g", interp.Numeric.FromInt64(2))
+	locals.Insert("x", interp.Numeric.FromInt64(3))
+	interp.frames = []*Frame{{Vars: locals, Parent: globals}}
+	interp.Variables = locals
+	s := &DAPServer{out: &bytes.Buffer{}, interp: interp, stopped: &EmptyStmt{}}
+
+	set := func(ref int, name, value string) error {
+		args, _ := json.Marshal(map[string]interface{}{"variablesReference": ref, "name": name, "value": value})
+		return s.setVariable(&dapRequest{Command: "setVariable", Arguments: args})
+	}
+	if err := set(dapVariablesRef, "x", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := set(dapGlobalsRef, "x", "20"); err != nil {
+		t.Fatal(err)
+	}
+	if got := interp.DecimalToBase(locals.Search("x"), 10); got != "10" {
+		t.Errorf("локальная x = %s, ожидалось 10", got)
+	}
+	if got := interp.DecimalToBase(globals.Search("x"), 10); got != "20" {
+		t.Errorf("глобальная x = %s, ожидалось 20", got)
+	}
+	if interp.Variables != locals {
+		t.Error("после записи в глобальные Variables не восстановлены")
+	}
+
+	// g видна из функции, но среди локальных её нет
+	for _, test := range []struct {
+		ref  int
+		name string
+	}{{dapVariablesRef, "y"}, {dapGlobalsRef, "y"}, {dapVariablesRef, "g"}, {dapWatchesRef, "x"}} {
+		if err := set(test.ref, test.name, "5"); err == nil {
+			t.Errorf("%s в области %d: ожидалась ошибка", test.name, test.ref)
+		}
+	}
+	if locals.Search("y") != nil || globals.Search("y") != nil || locals.Search("g") != nil {
+		t.Error("setVariable создал переменную")
+	}
+}
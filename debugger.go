@@ -0,0 +1,296 @@
+package interp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Отладчик, вызываемый перед инструкцией при остановке; false - завершить выполнение программы.
+// reason: "marker" - #BREAKPOINT, "breakpoint" - точка останова, "step" - пошаговое выполнение,
+// "watch" - изменилось наблюдаемое выражение, stmt - только что выполненная инструкция
+type Debugger interface {
+	Break(interp *Interpreter, stmt Stmt, reason string) bool
+}
+
+// остановка: отладчик интерпретатора или интерактивное меню на его вводе и выводе
+func (interp *Interpreter) breakpoint(stmt Stmt, reason string) bool {
+	debugger := interp.Debugger
+	if debugger == nil {
+		debugger = &ConsoleDebugger{In: interp.In, Out: interp.Out}
+	}
+	return debugger.Break(interp, stmt, reason)
+}
+
+// Интерактивное меню отладчика: команды читаются из In, ответы выводятся в Out
+type ConsoleDebugger struct {
+	In  io.Reader
+	Out io.Writer
+}
+
+func (c *ConsoleDebugger) Break(interp *Interpreter, stmt Stmt, reason string) bool {
+	if reason == "watch" {
+		fmt.Fprintf(c.Out, "Остановка после инструкции %d (%s)\n", stmt.Number(), stmt.Pos())
+		for _, change := range interp.watchChanges() {
+			fmt.Fprintln(c.Out, change)
+		}
+	} else {
+		fmt.Fprintf(c.Out, "Остановка перед инструкцией %d (%s)\n", stmt.Number(), stmt.Pos())
+	}
+	return interp.DebugPrompt(c.In, c.Out)
+}
+
+// Общие действия меню отладчика и сценария
+
+// значение переменной в десятичной записи и двоичное представление по байтам
+func (interp *Interpreter) debugShow(name string) ([]string, error) {
+	value := interp.lookup(name)
+	if value == nil {
+		return nil, fmt.Errorf("переменная %q не объявлена", name)
+	}
+	binaryValue := interp.Numeric.FormatBinary(value)
+	sign := ""
+	if strings.HasPrefix(binaryValue, "-") {
+		sign, binaryValue = "-", binaryValue[1:]
+	}
+	return []string{
+		fmt.Sprintf("%s = %s", name, interp.DecimalToBase(value, 10)),
+		sign + strings.Join(splitByWidth(binaryValue, 8), " "),
+	}, nil
+}
+
+// все переменные в десятичной записи
+func (interp *Interpreter) debugVariables() map[string]string {
+	variables := make(map[string]string)
+	for _, name := range interp.Variables.ObtainAll() {
+		variables[name] = interp.DecimalToBase(interp.Variables.Search(name), 10)
+	}
+	return variables
+}
+
+func (interp *Interpreter) debugSet(name, hexValue string) error {
+	if interp.Variables.Search(name) == nil {
+		return fmt.Errorf("переменная %q не объявлена", name)
+	}
+	value, err := interp.Numeric.Parse(hexValue, 16)
+	if err != nil {
+		return fmt.Errorf("некорректное значение %q: %v", hexValue, err)
+	}
+	interp.setVariable(name, value, nil)
+	return nil
+}
+
+func (interp *Interpreter) debugDeclareRoman(name, roman string) (int, error) {
+	if interp.Variables.Search(name) != nil {
+		return 0, fmt.Errorf("переменная %q уже объявлена", name)
+	}
+	value := interp.RomanToInt(strings.ToUpper(roman))
+	interp.setVariable(name, interp.Numeric.FromInt64(int64(value)), nil)
+	return value, nil
+}
+
+func (interp *Interpreter) debugDeclareZeckendorf(name string, fibNums []int) (int, error) {
+	if interp.Variables.Search(name) != nil {
+		return 0, fmt.Errorf("переменная %q уже объявлена", name)
+	}
+	if !interp.IsZeckendorf(fibNums, interp.FibSequence(1000000)) {
+		return 0, fmt.Errorf("недопустимое цекендорфово представление")
+	}
+	value := interp.ZeckendorfToInt(fibNums)
+	interp.setVariable(name, interp.Numeric.FromInt64(int64(value)), nil)
+	return value, nil
+}
+
+func (interp *Interpreter) debugDelete(name string) error {
+	if interp.Variables.Search(name) == nil {
+		return fmt.Errorf("переменная %q не объявлена", name)
+	}
+	interp.setVariable(name, nil, nil)
+	return nil
+}
+
+// Команда сценария отладки
+type debugCommand struct {
+	line int
+	name string
+	args []string
+}
+
+// имя команды и допустимое число аргументов (-1 - любое, не меньше min)
+var debugCommandArgs = map[string]struct{ min, max int }{
+	"print":    {1, 1},
+	"dump":     {0, 0},
+	"set":      {2, 2},
+	"declare":  {3, -1},
+	"delete":   {1, 1},
+	"break":    {1, -1},
+	"clear":    {1, 1},
+	"breaks":   {0, 0},
+	"watch":    {1, -1},
+	"unwatch":  {1, -1},
+	"watches":  {0, 0},
+	"history":  {1, 1},
+	"step":     {0, 0},
+	"next":     {0, 0},
+	"finish":   {0, 0},
+	"stack":    {0, 0},
+	"continue": {0, 0},
+	"quit":     {0, 0},
+}
+
+// Неинтерактивный отладчик: команды читаются из файла сценария,
+// каждое срабатывание точки останова и каждая команда пишутся в протокол JSON Lines
+type ScriptDebugger struct {
+	commands   []debugCommand
+	next       int
+	hits       int
+	transcript *json.Encoder
+}
+
+// Запись протокола отладки
+type debugEvent struct {
+	Event     string            `json:"event"`
+	Reason    string            `json:"reason,omitempty"`
+	Hit       int               `json:"hit,omitempty"`
+	Stmt      int               `json:"stmt,omitempty"`
+	Pos       string            `json:"pos,omitempty"`
+	Line      int               `json:"line,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Output    []string          `json:"output,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Changes   []string          `json:"changes,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Stack     []string          `json:"stack,omitempty"` // стек вызовов, если остановка в функции
+}
+
+//загрузка сценария; ошибки - с номерами строк
+func NewScriptDebugger(file string, transcript io.Writer) (*ScriptDebugger, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &ScriptDebugger{transcript: json.NewEncoder(transcript)}
+	d.transcript.SetEscapeHTML(false)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		words := strings.Fields(text)
+		if len(words) == 0 {
+			continue
+		}
+		name := strings.ToLower(words[0])
+		arity, ok := debugCommandArgs[name]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: неизвестная команда отладчика %q", file, line, words[0])
+		}
+		args := words[1:]
+		if len(args) < arity.min || (arity.max >= 0 && len(args) > arity.max) {
+			return nil, fmt.Errorf("%s:%d: неверное число аргументов команды %s", file, line, name)
+		}
+		if name == "declare" && args[1] != "roman" && args[1] != "zeckendorf" {
+			return nil, fmt.Errorf("%s:%d: тип значения должен быть roman или zeckendorf", file, line)
+		}
+		if name == "declare" && args[1] == "roman" && len(args) != 3 {
+			return nil, fmt.Errorf("%s:%d: неверное число аргументов команды declare", file, line)
+		}
+		if name == "break" || name == "clear" {
+			if _, err := ParseBreakpoint(strings.Join(args, " ")); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+			}
+		}
+		d.commands = append(d.commands, debugCommand{line: line, name: name, args: args})
+	}
+	return d, scanner.Err()
+}
+
+// выполнение команд сценария до continue, step, next, finish или quit; по концу сценария выполнение продолжается
+func (d *ScriptDebugger) Break(interp *Interpreter, stmt Stmt, reason string) bool {
+	d.hits++
+	event := debugEvent{
+		Event:     "breakpoint",
+		Reason:    reason,
+		Hit:       d.hits,
+		Stmt:      stmt.Number(),
+		Pos:       stmt.Pos().String(),
+		Variables: interp.debugVariables(),
+	}
+	if reason == "watch" {
+		event.Changes = interp.watchChanges()
+	}
+	if len(interp.frames) > 0 {
+		event.Stack = interp.CallStack(stmt.Pos())
+	}
+	d.transcript.Encode(event)
+	for d.next < len(d.commands) {
+		cmd := d.commands[d.next]
+		d.next++
+		if cmd.name == "quit" {
+			d.transcript.Encode(debugEvent{Event: cmd.name, Line: cmd.line})
+			return false
+		}
+
+		text := strings.Join(append([]string{cmd.name}, cmd.args...), " ")
+		output, resume, handled, err := interp.debuggerCommand(text)
+		if resume {
+			d.transcript.Encode(debugEvent{Event: cmd.name, Line: cmd.line})
+			return true
+		}
+		if !handled {
+			output, err = d.run(interp, cmd)
+		}
+		event := debugEvent{Event: "command", Line: cmd.line, Command: text, Output: output}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		d.transcript.Encode(event)
+	}
+	d.transcript.Encode(debugEvent{Event: "continue"})
+	return true
+}
+
+func (d *ScriptDebugger) run(interp *Interpreter, cmd debugCommand) ([]string, error) {
+	switch cmd.name {
+	case "print":
+		return interp.debugShow(cmd.args[0])
+	case "dump":
+		variables := interp.debugVariables()
+		var output []string
+		for name, value := range variables {
+			output = append(output, name+" = "+value)
+		}
+		sort.Strings(output)
+		return output, nil
+	case "set":
+		return nil, interp.debugSet(cmd.args[0], cmd.args[1])
+	case "declare":
+		var value int
+		var err error
+		if cmd.args[1] == "roman" {
+			value, err = interp.debugDeclareRoman(cmd.args[0], cmd.args[2])
+		} else {
+			var fibNums []int
+			for _, text := range cmd.args[2:] {
+				n, convErr := strconv.Atoi(text)
+				if convErr != nil {
+					return nil, fmt.Errorf("некорректное число %q", text)
+				}
+				fibNums = append(fibNums, n)
+			}
+			value, err = interp.debugDeclareZeckendorf(cmd.args[0], fibNums)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("%s = %d", cmd.args[0], value)}, nil
+	case "delete":
+		return nil, interp.debugDelete(cmd.args[0])
+	}
+	return nil, fmt.Errorf("неизвестная команда %s", cmd.name)
+}
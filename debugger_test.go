@@ -0,0 +1,30 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// без Debugger меню отладчика работает через ввод и вывод интерпретатора
+func TestConsoleDebuggerUsesInterpreterIO(t *testing.T) {
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp.Debug = true
+	interp.In, interp.Out = strings.NewReader("2\n7\n"), &out
+	if err := interp.Execute("x = 3;\n#BREAKPOINT\noutput(x);\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Остановка перед инструкцией 2", "DEBUG> x = 3"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("в выводе нет %q:\n%s", want, out.String())
+		}
+	}
+	// команда 7 завершает выполнение, output не выполняется
+	if strings.Count(out.String(), "x = 3") != 1 {
+		t.Errorf("выполнение продолжилось после завершения:\n%s", out.String())
+	}
+}
@@ -0,0 +1,169 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Серьёзность диагностики
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityNote
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "предупреждение"
+	case SeverityNote:
+		return "замечание"
+	default:
+		return "ошибка"
+	}
+}
+
+// Коды диагностик
+const (
+	CodeIllegalChar      = "E001"
+	CodeUnclosedComment  = "E002"
+	CodeSyntax           = "E003"
+	CodeOperandPlacement = "E004"
+	CodeArity            = "E005"
+	CodeAssignTarget     = "E006"
+	CodeLiteralLength    = "E007"
+	CodeNesting          = "E008"
+	CodeUndefinedVar     = "E101"
+	CodeDivisionByZero   = "E102"
+	CodeBadLiteral       = "E103"
+	CodeMisplacedIO      = "E104"
+	CodeBadInput         = "E105"
+	CodeUndefinedFunc    = "E106"
+	CodeCallDepth        = "E107"
+	CodeStepLimit        = "E108"
+	CodeTimeLimit        = "E109"
+	CodeVariableLimit    = "E110"
+	CodeValueLimit       = "E111"
+)
+
+// Диагностика; Stmt - номер инструкции с 1, 0 если неизвестен
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	Span     Span
+	Stmt     int
+
+	cause error // ошибка, которую диагностика уточняет, для errors.Is
+}
+
+func newDiagnostic(code string, span Span, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     span,
+	}
+}
+
+func (d *Diagnostic) Error() string {
+	msg := fmt.Sprintf("%s: %s[%s]: %s", d.Span, d.Severity, d.Code, d.Message)
+	if d.Stmt > 0 {
+		msg += fmt.Sprintf(" (инструкция %d)", d.Stmt)
+	}
+	return msg
+}
+
+func (d *Diagnostic) Unwrap() error {
+	return d.cause
+}
+
+// Сообщение с исходной строкой и подчёркиванием
+func (d *Diagnostic) Render(src string) string {
+	var sb strings.Builder
+	sb.WriteString(d.Error())
+	sb.WriteByte('\n')
+
+	lines := strings.Split(src, "\n")
+	line := d.Span.Start.Line
+	if line < 1 || line > len(lines) {
+		return sb.String()
+	}
+	text := strings.TrimRight(lines[line-1], "\r")
+	number := fmt.Sprint(line)
+	gutter := strings.Repeat(" ", len(number))
+
+	width := 1
+	if d.Span.End.Line == line && d.Span.End.Col > d.Span.Start.Col {
+		width = d.Span.End.Col - d.Span.Start.Col
+	} else if d.Span.End.Line > line {
+		width = utf8.RuneCountInString(text) - d.Span.Start.Col + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	// табуляции сохраняются, чтобы ^ встал под нужный символ
+	var pad strings.Builder
+	for i, r := range []rune(text) {
+		if i >= d.Span.Start.Col-1 {
+			break
+		}
+		if r == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+
+	fmt.Fprintf(&sb, " %s | %s\n", number, text)
+	fmt.Fprintf(&sb, " %s | %s^%s\n", gutter, pad.String(), strings.Repeat("~", width-1))
+	return sb.String()
+}
+
+// Список диагностик, возвращается как одна ошибка
+type Diagnostics []*Diagnostic
+
+func (ds Diagnostics) Error() string {
+	msgs := make([]string, len(ds))
+	for i, d := range ds {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (ds Diagnostics) Unwrap() []error {
+	errs := make([]error, len(ds))
+	for i, d := range ds {
+		errs[i] = d
+	}
+	return errs
+}
+
+func (ds Diagnostics) Render(src string) string {
+	var sb strings.Builder
+	for _, d := range ds {
+		sb.WriteString(d.Render(src))
+	}
+	return sb.String()
+}
+
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ошибка как nil или Diagnostics
+func (ds Diagnostics) Err() error {
+	if len(ds) == 0 {
+		return nil
+	}
+	return ds
+}
@@ -0,0 +1,64 @@
+package interp
+
+import (
+	"errors"
+	"testing"
+)
+
+// текст диагностик с исходной строкой и подчёркиванием; столбцы считаются в символах, а не байтах
+func TestDiagnosticRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		program string
+		want    string
+	}{
+		{"лексер", "x = 5 @ 3;\n",
+			"1:7: ошибка[E001]: недопустимый символ \"@\" (инструкция 1)\n" +
+				" 1 | x = 5 @ 3;\n" +
+				"   |       ^\n"},
+		{"незакрытый комментарий", "x = 1;\n[ не закрыт\n",
+			"2:1: ошибка[E002]: незакрытый блок комментария (инструкция 2)\n" +
+				" 2 | [ не закрыт\n" +
+				"   | ^~~~~~~~~~~\n"},
+		{"парсер", "переменная = add(1 2);\n",
+			"1:20: ошибка[E003]: ожидалось ), найдено \"2\" (инструкция 1)\n" +
+				" 1 | переменная = add(1 2);\n" +
+				"   |                    ^\n"},
+		{"выполнение", "переменная = 1;\nрезультат = add(переменная, неизвестная);\n",
+			"2:29: ошибка[E101]: переменная неизвестная не объявлена (инструкция 2)\n" +
+				" 2 | результат = add(переменная, неизвестная);\n" +
+				"   |                             ^~~~~~~~~~~\n"},
+		{"табуляция", "x = 1;\nwhile x {\n\tx = sub(x, ещё);\n}\n",
+			"3:13: ошибка[E101]: переменная ещё не объявлена (инструкция 3)\n" +
+				" 3 | \tx = sub(x, ещё);\n" +
+				"   | \t           ^~~\n"},
+	}
+	for _, test := range tests {
+		interp, err := New(NewDialect())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var diags Diagnostics
+		if err := interp.Execute(test.program); !errors.As(err, &diags) {
+			t.Fatalf("%s: ожидались диагностики, получено %v", test.name, err)
+		}
+		if got := diags.Render(test.program); got != test.want {
+			t.Errorf("%s:\n%s\nожидалось:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSettingsDiagnosticRender(t *testing.T) {
+	settings := "left=\nadd сумма\nmult сумма\n"
+	want := "settings.txt:3:6: ошибка[S003]: синоним \"сумма\" уже занят операцией add в строке 2\n" +
+		" 3 | mult сумма\n" +
+		"   |      ^~~~~\n"
+	_, err := ParseSettings("settings.txt", settings)
+	var diags Diagnostics
+	if !errors.As(err, &diags) {
+		t.Fatalf("ожидались диагностики, получено %v", err)
+	}
+	if got := diags.Render(settings); got != want {
+		t.Errorf("\n%s\nожидалось:\n%s", got, want)
+	}
+}
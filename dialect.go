@@ -0,0 +1,472 @@
+package interp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Запись JSON/TOML: таблица ("" - корень), ключ и значение
+type settingsEntry struct {
+	table string
+	key   string
+	value string
+	span  Span
+}
+
+// порядок обработки таблиц: синонимы раньше приоритетов, заданных через синонимы
+var settingsTables = []string{"", "commands", "bases", "precedence", "associativity"}
+
+// Разбор диалекта в формате JSON
+func ParseJSONSettings(file string, data []byte) (*Dialect, error) {
+	fileSpan := Span{File: file}
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		span := fileSpan
+		if syntaxErr, ok := err.(*json.SyntaxError); ok {
+			span = offsetSpan(file, data, int(syntaxErr.Offset))
+		}
+		return nil, Diagnostics{newDiagnostic(CodeSettingsValue, span, "некорректный JSON: %v", err)}
+	}
+
+	spans := jsonSpans(file, data)
+	span := func(key string) Span {
+		if span, ok := spans[key]; ok {
+			return span
+		}
+		return fileSpan
+	}
+
+	var entries []settingsEntry
+	var diags Diagnostics
+	for key, raw := range root {
+		if isSettingsTable(key) {
+			var table map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &table); err != nil {
+				diags = append(diags, newDiagnostic(CodeSettingsValue, span(key), "%s должен быть объектом", key))
+				continue
+			}
+			for name, value := range table {
+				text, err := jsonScalar(value)
+				if err != nil {
+					diags = append(diags, newDiagnostic(CodeSettingsValue, span(key+"."+name), "%s.%s: %v", key, name, err))
+					continue
+				}
+				entries = append(entries, settingsEntry{key, name, text, span(key + "." + name)})
+			}
+			continue
+		}
+		text, err := jsonScalar(raw)
+		if err != nil {
+			diags = append(diags, newDiagnostic(CodeSettingsValue, span(key), "%s: %v", key, err))
+			continue
+		}
+		entries = append(entries, settingsEntry{"", key, text, span(key)})
+	}
+
+	p := newSettingsParser(file)
+	p.diags = diags
+	p.entries(entries)
+	return p.finish()
+}
+
+// строка или число из JSON как текст директивы
+func jsonScalar(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var number json.Number
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&number); err == nil {
+		return number.String(), nil
+	}
+	return "", fmt.Errorf("ожидалась строка или число")
+}
+
+// строка и столбец по смещению в байтах
+func offsetSpan(file string, data []byte, offset int) Span {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	col := 1 + utf8.RuneCount(data[bytes.LastIndexByte(data[:offset], '\n')+1:offset])
+	pos := Pos{Offset: offset, Line: line, Col: col}
+	return Span{File: file, Start: pos, End: pos}
+}
+
+// Места пар "ключ": значение в JSON: "ключ" для корня и "таблица.ключ" для таблиц,
+// от начала ключа до конца значения
+func jsonSpans(file string, data []byte) map[string]Span {
+	spans := make(map[string]Span)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	var object func(prefix string) bool
+	object = func(prefix string) bool {
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return false
+		}
+		for dec.More() {
+			start := int(dec.InputOffset())
+			tok, err := dec.Token()
+			key, ok := tok.(string)
+			if err != nil || !ok {
+				return false
+			}
+			// до ключа могут стоять пробелы и запятая
+			start += bytes.IndexByte(data[start:], '"')
+			name := prefix + key
+			var raw json.RawMessage
+			if prefix == "" && isSettingsTable(key) && bytes.HasPrefix(bytes.TrimLeft(data[dec.InputOffset():], " \t\r\n:"), []byte("{")) {
+				if !object(name + ".") {
+					return false
+				}
+			} else if dec.Decode(&raw) != nil {
+				return false
+			}
+			end := offsetSpan(file, data, int(dec.InputOffset()))
+			spans[name] = Span{File: file, Start: offsetSpan(file, data, start).Start, End: end.Start}
+		}
+		_, err := dec.Token()
+		return err == nil
+	}
+	object("")
+	return spans
+}
+
+func isSettingsTable(name string) bool {
+	return name != "" && tableIndex(name) >= 0
+}
+
+func tableIndex(name string) int {
+	for i, table := range settingsTables {
+		if table == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Разбор диалекта в формате TOML (таблицы, строки, целые числа)
+func ParseTOMLSettings(file, src string) (*Dialect, error) {
+	p := newSettingsParser(file)
+	table := ""
+	var entries []settingsEntry
+	for i, line := range strings.Split(src, "\n") {
+		number := i + 1
+		text := strings.TrimSpace(line)
+		span := p.span(number, 1+len([]rune(line))-len([]rune(strings.TrimLeft(line, " \t"))), text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			end := strings.Index(text, "]")
+			if end < 0 || strings.TrimSpace(stripTOMLComment(text[end+1:])) != "" {
+				p.errorf(CodeSettingsValue, span, "некорректный заголовок таблицы")
+				continue
+			}
+			table = strings.TrimSpace(text[1:end])
+			if !isSettingsTable(table) {
+				p.errorf(CodeSettingsUnknown, span, "неизвестная таблица [%s]", table)
+			}
+			continue
+		}
+
+		key, rest, err := tomlKey(text)
+		if err == nil {
+			rest = strings.TrimSpace(rest)
+			if !strings.HasPrefix(rest, "=") {
+				err = fmt.Errorf("ожидалось =")
+			}
+		}
+		var value string
+		if err == nil {
+			value, rest, err = tomlValue(strings.TrimSpace(rest[1:]))
+		}
+		if err == nil && strings.TrimSpace(stripTOMLComment(rest)) != "" {
+			err = fmt.Errorf("лишний текст после значения")
+		}
+		if err != nil {
+			p.errorf(CodeSettingsValue, span, "%v", err)
+			continue
+		}
+		entries = append(entries, settingsEntry{table, key, value, span})
+	}
+	p.entries(entries)
+	return p.finish()
+}
+
+func stripTOMLComment(s string) string {
+	if i := strings.Index(s, "#"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// ключ TOML: простой или в кавычках
+func tomlKey(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return tomlString(s)
+	}
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end == 0 {
+		return "", "", fmt.Errorf("ожидался ключ")
+	}
+	if end < 0 {
+		end = len(s)
+	}
+	return s[:end], s[end:], nil
+}
+
+// значение TOML: строка или целое число
+func tomlValue(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		return tomlString(s)
+	}
+	end := strings.IndexAny(s, " \t#")
+	if end < 0 {
+		end = len(s)
+	}
+	if _, err := strconv.Atoi(s[:end]); err != nil {
+		return "", "", fmt.Errorf("ожидалась строка или целое число, найдено %q", s[:end])
+	}
+	return s[:end], s[end:], nil
+}
+
+// строка в двойных (с экранированием) или одинарных кавычках
+func tomlString(s string) (string, string, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		if quote == '"' && s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] != quote {
+			continue
+		}
+		if quote == '\'' {
+			return s[1:i], s[i+1:], nil
+		}
+		text, err := strconv.Unquote(s[:i+1])
+		if err != nil {
+			return "", "", fmt.Errorf("некорректная строка %s", s[:i+1])
+		}
+		return text, s[i+1:], nil
+	}
+	return "", "", fmt.Errorf("незакрытая строка")
+}
+
+// записи JSON/TOML как директивы текстового формата
+func (p *settingsParser) entries(entries []settingsEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.table != b.table {
+			return tableIndex(a.table) < tableIndex(b.table)
+		}
+		if (a.key == "version") != (b.key == "version") {
+			return a.key == "version"
+		}
+		if a.span.Start.Line != b.span.Start.Line {
+			return a.span.Start.Line < b.span.Start.Line
+		}
+		return a.key < b.key
+	})
+
+	for _, e := range entries {
+		key := settingsField{e.key, e.span}
+		value := settingsField{e.value, e.span}
+		switch e.table {
+		case "":
+			switch e.key {
+			case "version", "numeric", "unary", "binary":
+				p.directive(key, []settingsField{value})
+			case "result":
+				if e.value != "left" && e.value != "right" {
+					p.errorf(CodeSettingsValue, e.span, "result: ожидалось left или right, найдено %s", e.value)
+				} else {
+					p.directive(settingsField{e.value + "=", e.span}, nil)
+				}
+			default:
+				p.errorf(CodeSettingsUnknown, e.span, "неизвестный ключ %q", e.key)
+			}
+		case "commands":
+			if isCommandName(e.key) {
+				p.synonym(key, value)
+			} else {
+				p.errorf(CodeSettingsUnknown, e.span, "неизвестная операция %q", e.key)
+			}
+		case "bases":
+			if e.key == "input" || e.key == "output" || e.key == "assign" {
+				p.baseDirective(settingsField{"base-" + e.key, e.span}, []settingsField{value})
+			} else {
+				p.errorf(CodeSettingsUnknown, e.span, "неизвестное основание %q, допустимы input, output, assign", e.key)
+			}
+		case "precedence":
+			p.precedenceDirective(settingsField{"precedence", e.span}, []settingsField{key, value})
+		case "associativity":
+			if op := p.binaryOp(key); op != "" {
+				p.associativity(op, value)
+			}
+		}
+	}
+}
+
+// Запись диалекта в формате txt, json или toml
+func (d *Dialect) Write(w io.Writer, format string) error {
+	switch format {
+	case "txt":
+		return d.writeText(w)
+	case "json":
+		return d.writeJSON(w)
+	case "toml":
+		return d.writeTOML(w)
+	}
+	return fmt.Errorf("неизвестный формат %q, допустимы txt, json, toml", format)
+}
+
+// синонимы, отличающиеся от исходных имён, в порядке commandNames
+func (d *Dialect) synonyms() [][2]string {
+	var result [][2]string
+	for _, name := range commandNames {
+		if synonym, ok := d.Commands[name]; ok && synonym != name {
+			result = append(result, [2]string{name, synonym})
+		}
+	}
+	return result
+}
+
+// основание системы счисления, заданное в диалекте
+type dialectBase struct {
+	name  string
+	value int
+}
+
+func (d *Dialect) bases() []dialectBase {
+	var result []dialectBase
+	for _, base := range []dialectBase{{"input", d.BaseInput}, {"output", d.BaseOutput}, {"assign", d.BaseAssign}} {
+		if base.value != 0 {
+			result = append(result, base)
+		}
+	}
+	return result
+}
+
+func (d *Dialect) binaryOps() []string {
+	var ops []string
+	for op := range d.Precedence {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func (d *Dialect) assoc(op string) string {
+	if d.RightAssoc[op] {
+		return "right"
+	}
+	return "left"
+}
+
+func (d *Dialect) writeText(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "version %d\n", d.Version)
+	fmt.Fprintf(&sb, "%s=\n", d.Result)
+	fmt.Fprintf(&sb, "unary %s\n", d.UnarySyntax)
+	fmt.Fprintf(&sb, "binary %s\n", d.BinarySyntax)
+	fmt.Fprintf(&sb, "numeric %s\n", d.Numeric)
+	for _, base := range d.bases() {
+		fmt.Fprintf(&sb, "base-%s %d\n", base.name, base.value)
+	}
+	for _, op := range d.binaryOps() {
+		fmt.Fprintf(&sb, "precedence %s %d %s\n", op, d.Precedence[op], d.assoc(op))
+	}
+	for _, synonym := range d.synonyms() {
+		fmt.Fprintf(&sb, "%s %s\n", synonym[0], synonym[1])
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (d *Dialect) writeJSON(w io.Writer) error {
+	doc := struct {
+		Version       int               `json:"version"`
+		Result        string            `json:"result"`
+		Unary         string            `json:"unary"`
+		Binary        string            `json:"binary"`
+		Numeric       string            `json:"numeric"`
+		Bases         map[string]int    `json:"bases,omitempty"`
+		Commands      map[string]string `json:"commands,omitempty"`
+		Precedence    map[string]int    `json:"precedence"`
+		Associativity map[string]string `json:"associativity"`
+	}{
+		Version:       d.Version,
+		Result:        d.Result,
+		Unary:         d.UnarySyntax,
+		Binary:        d.BinarySyntax,
+		Numeric:       d.Numeric,
+		Bases:         make(map[string]int),
+		Commands:      make(map[string]string),
+		Precedence:    d.Precedence,
+		Associativity: make(map[string]string),
+	}
+	for _, base := range d.bases() {
+		doc.Bases[base.name] = base.value
+	}
+	for _, synonym := range d.synonyms() {
+		doc.Commands[synonym[0]] = synonym[1]
+	}
+	for _, op := range d.binaryOps() {
+		doc.Associativity[op] = d.assoc(op)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+func (d *Dialect) writeTOML(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "version = %d\n", d.Version)
+	fmt.Fprintf(&sb, "result = %q\n", d.Result)
+	fmt.Fprintf(&sb, "unary = %q\n", d.UnarySyntax)
+	fmt.Fprintf(&sb, "binary = %q\n", d.BinarySyntax)
+	fmt.Fprintf(&sb, "numeric = %q\n", d.Numeric)
+	if bases := d.bases(); len(bases) != 0 {
+		sb.WriteString("\n[bases]\n")
+		for _, base := range bases {
+			fmt.Fprintf(&sb, "%s = %d\n", base.name, base.value)
+		}
+	}
+	if synonyms := d.synonyms(); len(synonyms) != 0 {
+		sb.WriteString("\n[commands]\n")
+		for _, synonym := range synonyms {
+			fmt.Fprintf(&sb, "%s = %q\n", tomlKeyText(synonym[0]), synonym[1])
+		}
+	}
+	sb.WriteString("\n[precedence]\n")
+	for _, op := range d.binaryOps() {
+		fmt.Fprintf(&sb, "%s = %d\n", op, d.Precedence[op])
+	}
+	sb.WriteString("\n[associativity]\n")
+	for _, op := range d.binaryOps() {
+		fmt.Fprintf(&sb, "%s = %q\n", op, d.assoc(op))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// ключ TOML, в кавычках если он не простой
+func tomlKeyText(key string) string {
+	if bare, rest, err := tomlKey(key); err == nil && rest == "" {
+		return bare
+	}
+	return strconv.Quote(key)
+}
@@ -0,0 +1,10 @@
+package interp
+
+// Программа в каноническом виде для текущего диалекта
+func (interp *Interpreter) Format(file, program string) (string, error) {
+	prog, err := interp.Parse(file, program)
+	if err != nil {
+		return "", err
+	}
+	return NewPrinter(interp).Program(prog)
+}
@@ -0,0 +1,52 @@
+package interp
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"пробелы и отступы",
+			"a=5;\nif a {\noutput(a);\n    b = add(a,1);\n} else {\n  output(0);\n}\n",
+			"a = 5;\nif a {\n\toutput(a);\n\tb = add(a, 1);\n} else {\n\toutput(0);\n}\n"},
+		// комментарии остаются на своих местах, подряд идущие пустые строки схлопываются в одну
+		{"комментарии и пустые строки",
+			"# заголовок\n\n\na=5;   # пять\nif a {\n# внутри\noutput(a);\n\n\n    b = add(a,1);\n} else {\n  output(0); # ноль\n}\n# в конце\n",
+			"# заголовок\n\na = 5; # пять\nif a {\n\t# внутри\n\toutput(a);\n\n\tb = add(a, 1);\n} else {\n\toutput(0); # ноль\n}\n# в конце\n"},
+		{"функция",
+			"func f(x,y){\n# тело\nreturn add(x,y);\n}\n\n\nz=f(1,2);\n",
+			"func f(x, y) {\n\t# тело\n\treturn add(x, y);\n}\n\nz = f(1, 2);\n"},
+	}
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		got, err := interp.Format(test.name, test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s:\n%q\nожидалось\n%q", test.name, got, test.want)
+		}
+	}
+}
+
+// повторное форматирование ничего не меняет
+func TestFormatIdempotent(t *testing.T) {
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range equivalenceCases {
+		once, err := interp.Format(test.name, test.program)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if twice, err := interp.Format(test.name, once); err != nil || twice != once {
+			t.Errorf("%s: Format(Format(x)) != Format(x):\n%s\n%s", test.name, once, twice)
+		}
+	}
+}
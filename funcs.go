@@ -0,0 +1,155 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Кадр вызова пользовательской функции: параметры и локальные переменные в своём дереве,
+// за ними при чтении ищутся глобальные
+type Frame struct {
+	Func   *FuncDecl
+	Vars   *Trie
+	Parent *Trie // глобальные переменные
+	Call   Span  // место вызова
+
+	result   Value
+	returned bool
+}
+
+const defaultMaxCallDepth = 1000
+
+// выход из функции по требованию отладчика
+var errStopped = errors.New("выполнение остановлено отладчиком")
+
+// кадр выполняющейся функции; nil - вне функций
+func (interp *Interpreter) frame() *Frame {
+	if len(interp.frames) == 0 {
+		return nil
+	}
+	return interp.frames[len(interp.frames)-1]
+}
+
+// Глобальные переменные; внутри функции Variables - её локальные переменные
+func (interp *Interpreter) Globals() *Trie {
+	if len(interp.frames) > 0 {
+		return interp.frames[0].Parent
+	}
+	return interp.Variables
+}
+
+// значение переменной: локальные затеняют глобальные
+func (interp *Interpreter) lookup(name string) Value {
+	if value := interp.Variables.Search(name); value != nil {
+		return value
+	}
+	if len(interp.frames) > 0 {
+		return interp.Globals().Search(name)
+	}
+	return nil
+}
+
+// в функции выполнен return: оставшиеся инструкции её тела пропускаются
+func (interp *Interpreter) returning() bool {
+	f := interp.frame()
+	return f != nil && f.returned
+}
+
+// регистрация функций программы до выполнения: вызов может стоять раньше объявления
+func (interp *Interpreter) declareFunctions(stmts []Stmt) {
+	for _, stmt := range stmts {
+		if decl, ok := stmt.(*FuncDecl); ok {
+			interp.Functions[decl.Name.Text] = decl
+		}
+	}
+}
+
+func (interp *Interpreter) maxCallDepth() int {
+	if interp.MaxCallDepth == 0 {
+		return defaultMaxCallDepth
+	}
+	return interp.MaxCallDepth
+}
+
+func undefinedFunction(call *FuncCall) error {
+	return newDiagnostic(CodeUndefinedFunc, call.Span, "функция %s не объявлена", call.Name.Text)
+}
+
+func callDepthExceeded(call *FuncCall, limit int) error {
+	return newDiagnostic(CodeCallDepth, call.Span, "превышена глубина вызовов %d", limit)
+}
+
+// вызов пользовательской функции; без return результат 0
+func (interp *Interpreter) callFunction(call *FuncCall) (Value, error) {
+	decl := interp.Functions[call.Name.Text]
+	if decl == nil {
+		return nil, undefinedFunction(call)
+	}
+	args := make([]Value, len(call.Args))
+	for i, arg := range call.Args {
+		value, err := interp.EvaluateExpression(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	if len(interp.frames) >= interp.maxCallDepth() {
+		return nil, callDepthExceeded(call, interp.maxCallDepth())
+	}
+	if limit := interp.Limits.MaxVariables; limit > 0 && len(decl.Params) > limit {
+		return nil, interp.exceed(variableLimit(call.Span, limit), interp.current)
+	}
+
+	f := &Frame{Func: decl, Vars: NewTrie(), Parent: interp.Globals(), Call: call.Span}
+	for i, param := range decl.Params {
+		f.Vars.Insert(param.Text, args[i])
+	}
+	outer, keepGoing := interp.Variables, interp.KeepGoing
+	interp.frames = append(interp.frames, f)
+	interp.Variables, interp.KeepGoing = f.Vars, false
+	var diags Diagnostics
+	completed := interp.executeBlock(decl.Body.Stmts, &diags)
+	interp.frames = interp.frames[:len(interp.frames)-1]
+	interp.Variables, interp.KeepGoing = outer, keepGoing
+
+	switch {
+	case len(diags) > 0:
+		return nil, diags[0]
+	case !completed:
+		return nil, errStopped
+	case f.result == nil:
+		return interp.Numeric.FromInt64(0), nil
+	}
+	return f.result, nil
+}
+
+// Стек вызовов от выполняющейся функции к программе; current - текущее место выполнения
+func (interp *Interpreter) CallStack(current Span) []string {
+	var lines []string
+	for i := len(interp.frames) - 1; i >= 0; i-- {
+		f := interp.frames[i]
+		params := make([]string, len(f.Func.Params))
+		for j, param := range f.Func.Params {
+			params[j] = param.Text + " = " + interp.DecimalToBase(f.Vars.Search(param.Text), 10)
+		}
+		lines = append(lines, fmt.Sprintf("%s(%s) — %s", f.Func.Name.Text, strings.Join(params, ", "), current))
+		current = f.Call
+	}
+	return append(lines, fmt.Sprintf("<программа> — %s", current))
+}
+
+// Пошаговое выполнение: "in" - до следующей инструкции, "over" - без захода в вызовы,
+// "out" - до возврата из текущей функции
+func (interp *Interpreter) Step(mode string) {
+	interp.Stepping = true
+	switch mode {
+	case "over":
+		interp.stepDepth = len(interp.frames)
+	case "out":
+		interp.stepDepth = len(interp.frames) - 1
+	default:
+		interp.stepDepth = math.MaxInt32
+	}
+}
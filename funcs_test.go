@@ -0,0 +1,110 @@
+package interp
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// интерпретатор, выполняющий программы обходом дерева
+func treeWalker(t *testing.T) (*Interpreter, *bytes.Buffer) {
+	t.Helper()
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp.In, interp.Out, interp.TreeWalk = strings.NewReader(""), &out, true
+	return interp, &out
+}
+
+// параметры и локальные переменные затеняют глобальные и не меняют их
+func TestLocalsShadowGlobals(t *testing.T) {
+	interp, out := treeWalker(t)
+	program := `
+x = 1;
+a = 7;
+func f(a) {
+	x = add(a, 10);
+	return add(x, g);
+}
+g = 100;
+y = f(5);
+output(x);
+output(a);
+output(y);
+`
+	if err := interp.Execute(program); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "x = 1\na = 7\ny = 115\n"; got != want {
+		t.Errorf("вывод %q, ожидалось %q", got, want)
+	}
+	if interp.frame() != nil || interp.Variables != interp.Globals() {
+		t.Error("после вызова остался кадр функции")
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	program := `
+func down(n) {
+	if n {
+		return add(down(sub(n, 1)), 1);
+	}
+	return 0;
+}
+n = input();
+y = down(n);
+output(y);
+`
+	interp, out := treeWalker(t)
+	interp.MaxCallDepth = 50
+	interp.In = strings.NewReader("40\n")
+	if err := interp.Execute(program); err != nil || out.String() != "y = 40\n" {
+		t.Errorf("рекурсия в пределах глубины: %q, %v", out.String(), err)
+	}
+
+	interp, _ = treeWalker(t)
+	interp.MaxCallDepth = 50
+	interp.In = strings.NewReader("60\n")
+	err := interp.Execute(program)
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 || diags[0].Code != CodeCallDepth {
+		t.Fatalf("ожидалась ошибка %s, получено %v", CodeCallDepth, err)
+	}
+	if interp.frame() != nil || interp.Variables != interp.Globals() {
+		t.Error("после ошибки остались кадры функций")
+	}
+}
+
+// ошибка в теле функции прерывает функцию даже с KeepGoing,
+// а программа после вызова продолжается
+func TestKeepGoingOffInFunction(t *testing.T) {
+	interp, out := treeWalker(t)
+	interp.KeepGoing = true
+	program := `
+func f(a) {
+	x = div(a, 0);
+	output(a);
+	return a;
+}
+y = f(5);
+z = 3;
+output(z);
+`
+	err := interp.Execute(program)
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 1 || diags[0].Code != CodeDivisionByZero {
+		t.Fatalf("ожидалась ошибка %s, получено %v", CodeDivisionByZero, err)
+	}
+	if got := out.String(); got != "z = 3\n" {
+		t.Errorf("вывод %q, ожидалось %q", got, "z = 3\n")
+	}
+	if interp.Variables.Search("y") != nil {
+		t.Error("y присвоено, хотя вызов завершился ошибкой")
+	}
+	if !interp.KeepGoing {
+		t.Error("KeepGoing не восстановлен после вызова")
+	}
+}
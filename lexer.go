@@ -0,0 +1,283 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Вид токена
+type TokenKind int
+
+const (
+	TokEOF TokenKind = iota
+	TokIdent
+	TokNumber
+	TokOp
+	TokLParen
+	TokRParen
+	TokLBrace
+	TokRBrace
+	TokComma
+	TokAssign
+	TokSemicolon
+	TokComment
+	TokBreakpoint
+	TokKeyword
+	TokIllegal
+)
+
+var tokenKindNames = map[TokenKind]string{
+	TokEOF:        "конец файла",
+	TokIdent:      "идентификатор",
+	TokNumber:     "число",
+	TokOp:         "операция",
+	TokLParen:     "(",
+	TokRParen:     ")",
+	TokLBrace:     "{",
+	TokRBrace:     "}",
+	TokComma:      ",",
+	TokAssign:     "присваивание",
+	TokSemicolon:  ";",
+	TokComment:    "комментарий",
+	TokBreakpoint: "#BREAKPOINT",
+	TokKeyword:    "ключевое слово",
+	TokIllegal:    "недопустимый символ",
+}
+
+func (k TokenKind) String() string {
+	return tokenKindNames[k]
+}
+
+// Позиция в исходнике (строки и столбцы с 1, столбцы в рунах)
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+// Диапазон исходника
+type Span struct {
+	File  string
+	Start Pos
+	End   Pos
+}
+
+func (s Span) String() string {
+	if s.Start.Line == 0 {
+		return s.File
+	}
+	if s.File == "" {
+		return fmt.Sprintf("%d:%d", s.Start.Line, s.Start.Col)
+	}
+	return fmt.Sprintf("%s:%d:%d", s.File, s.Start.Line, s.Start.Col)
+}
+
+// Токен; для операций Op хранит исходное имя команды, Text - синоним из программы
+type Token struct {
+	Kind TokenKind
+	Text string
+	Op   string
+	Span Span
+}
+
+const breakpointMarker = "#BREAKPOINT"
+
+// Лексер
+type Lexer struct {
+	file    string
+	src     string
+	pos     Pos
+	words   map[string]string
+	symbols *Trie
+}
+
+//создание, синонимы берутся из таблицы команд
+func NewLexer(file, src string, commands map[string]string) *Lexer {
+	lx := &Lexer{
+		file:    file,
+		src:     src,
+		pos:     Pos{Line: 1, Col: 1},
+		words:   make(map[string]string),
+		symbols: NewTrie(),
+	}
+	for original, synonym := range commands {
+		if synonym == "" {
+			continue
+		}
+		lx.words[synonym] = original
+		if !isWord(synonym) {
+			lx.symbols.Insert(synonym, original)
+		}
+	}
+	return lx
+}
+
+func isWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !isIdentRune(r, i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIdentRune(r rune, first bool) bool {
+	if r == '_' || unicode.IsLetter(r) {
+		return true
+	}
+	return !first && unicode.IsDigit(r)
+}
+
+func (lx *Lexer) peek() rune {
+	if lx.pos.Offset >= len(lx.src) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(lx.src[lx.pos.Offset:])
+	return r
+}
+
+func (lx *Lexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(lx.src[lx.pos.Offset:])
+	lx.pos.Offset += size
+	if r == '\n' {
+		lx.pos.Line++
+		lx.pos.Col = 1
+	} else {
+		lx.pos.Col++
+	}
+	return r
+}
+
+func (lx *Lexer) eof() bool {
+	return lx.pos.Offset >= len(lx.src)
+}
+
+func (lx *Lexer) token(kind TokenKind, start Pos) Token {
+	return Token{
+		Kind: kind,
+		Text: lx.src[start.Offset:lx.pos.Offset],
+		Span: Span{File: lx.file, Start: start, End: lx.pos},
+	}
+}
+
+// Следующий токен
+func (lx *Lexer) Next() Token {
+	for !lx.eof() && unicode.IsSpace(lx.peek()) {
+		lx.advance()
+	}
+	start := lx.pos
+	if lx.eof() {
+		return lx.token(TokEOF, start)
+	}
+
+	rest := lx.src[lx.pos.Offset:]
+	switch r := lx.peek(); {
+	case r == '#':
+		if strings.HasPrefix(rest, breakpointMarker) {
+			for range breakpointMarker {
+				lx.advance()
+			}
+			return lx.token(TokBreakpoint, start)
+		}
+		for !lx.eof() && lx.peek() != '\n' {
+			lx.advance()
+		}
+		return lx.token(TokComment, start)
+	case r == '[':
+		return lx.blockComment(start)
+	}
+
+	// самый длинный символьный синоним: "<>" раньше "<"
+	if synonym := lx.symbols.LongestPrefixOf(rest); synonym != "" {
+		for range synonym {
+			lx.advance()
+		}
+		return lx.command(start)
+	}
+
+	switch r := lx.advance(); {
+	case r == '(':
+		return lx.token(TokLParen, start)
+	case r == ')':
+		return lx.token(TokRParen, start)
+	case r == '{':
+		return lx.token(TokLBrace, start)
+	case r == '}':
+		return lx.token(TokRBrace, start)
+	case r == ',':
+		return lx.token(TokComma, start)
+	case r == ';':
+		return lx.token(TokSemicolon, start)
+	case unicode.IsDigit(r):
+		for !lx.eof() && isIdentRune(lx.peek(), false) && lx.peek() != '_' {
+			lx.advance()
+		}
+		return lx.token(TokNumber, start)
+	case isIdentRune(r, true):
+		for !lx.eof() && isIdentRune(lx.peek(), false) {
+			lx.advance()
+		}
+		if _, ok := lx.words[lx.src[start.Offset:lx.pos.Offset]]; ok {
+			return lx.command(start)
+		}
+		return lx.token(TokIdent, start)
+	default:
+		return lx.token(TokIllegal, start)
+	}
+}
+
+// операция, присваивание или ключевое слово по синониму
+func (lx *Lexer) command(start Pos) Token {
+	tok := lx.token(TokOp, start)
+	tok.Op = lx.words[tok.Text]
+	if tok.Op == "=" {
+		tok.Kind = TokAssign
+	} else if isKeyword(tok.Op) {
+		tok.Kind = TokKeyword
+	}
+	return tok
+}
+
+// блок комментария [ ... ] с вложенностью
+func (lx *Lexer) blockComment(start Pos) Token {
+	depth := 0
+	for !lx.eof() {
+		switch lx.advance() {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return lx.token(TokComment, start)
+			}
+		}
+	}
+	return lx.token(TokIllegal, start)
+}
+
+// Все токены до конца файла включительно
+func (lx *Lexer) All() ([]Token, error) {
+	var tokens []Token
+	for {
+		tok := lx.Next()
+		tokens = append(tokens, tok)
+		switch tok.Kind {
+		case TokIllegal:
+			return tokens, illegalToken(tok)
+		case TokEOF:
+			return tokens, nil
+		}
+	}
+}
+
+func illegalToken(tok Token) *Diagnostic {
+	if strings.HasPrefix(tok.Text, "[") {
+		return newDiagnostic(CodeUnclosedComment, tok.Span, "незакрытый блок комментария")
+	}
+	return newDiagnostic(CodeIllegalChar, tok.Span, "недопустимый символ %q", tok.Text)
+}
@@ -0,0 +1,160 @@
+package interp
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+// Ограничения для недоверенных программ; нулевое значение - без ограничения.
+// Превышение любого из них прекращает выполнение, в том числе в режиме --keep-going
+type Limits struct {
+	MaxSteps      int64         // выполненных инструкций, включая повторные проверки условия цикла
+	Timeout       time.Duration // время выполнения
+	MaxVariables  int           // переменных в одной области: глобальных или одного вызова функции
+	MaxLiteralLen int           // цифр в литерале программы и во введённом значении
+	MaxNesting    int           // вложенность выражений и блоков
+	MaxValueBits  int           // размер значения в режиме big; при Timeout без него - defaultTimedValueBits
+}
+
+// Размер значения в режиме big при заданном Timeout: время проверяется между инструкциями,
+// а одна степень с огромным показателем без ограничения размера вычислялась бы сколь угодно долго
+const defaultTimedValueBits = 1 << 20
+
+// действующее ограничение размера значения; 0 - без ограничения
+func (l Limits) valueBits() int {
+	if l.MaxValueBits == 0 && l.Timeout > 0 {
+		return defaultTimedValueBits
+	}
+	return l.MaxValueBits
+}
+
+// Причины диагностик о превышении ограничений, проверяются errors.Is
+var (
+	ErrStepLimit     = errors.New("превышено число выполненных инструкций")
+	ErrTimeLimit     = errors.New("превышено время выполнения")
+	ErrVariableLimit = errors.New("превышено число переменных")
+	ErrLiteralLimit  = errors.New("превышена длина литерала")
+	ErrNestingLimit  = errors.New("превышена вложенность")
+	ErrValueLimit    = errors.New("превышен размер значения")
+)
+
+func limitDiagnostic(code string, cause error, span Span, format string, args ...interface{}) *Diagnostic {
+	d := newDiagnostic(code, span, format, args...)
+	d.cause = cause
+	return d
+}
+
+func stepLimit(stmt Stmt, limit int64) *Diagnostic {
+	return limitDiagnostic(CodeStepLimit, ErrStepLimit, stmtHeader(stmt), "превышено число выполненных инструкций %d", limit)
+}
+
+func timeLimit(stmt Stmt, limit time.Duration) *Diagnostic {
+	return limitDiagnostic(CodeTimeLimit, ErrTimeLimit, stmtHeader(stmt), "превышено время выполнения %s", limit)
+}
+
+func variableLimit(span Span, limit int) *Diagnostic {
+	return limitDiagnostic(CodeVariableLimit, ErrVariableLimit, span, "превышено число переменных %d", limit)
+}
+
+func literalLimit(span Span, limit int) *Diagnostic {
+	return limitDiagnostic(CodeLiteralLength, ErrLiteralLimit, span, "число длиннее %d цифр", limit)
+}
+
+func nestingLimit(span Span, limit int) *Diagnostic {
+	return limitDiagnostic(CodeNesting, ErrNestingLimit, span, "вложенность больше %d", limit)
+}
+
+func valueLimit(span Span, limit int) *Diagnostic {
+	return limitDiagnostic(CodeValueLimit, ErrValueLimit, span, "значение больше %d бит", limit)
+}
+
+// превышение ограничения в инструкции stmt (nil - вне инструкций): выполнение прекращается,
+// диагностика сохраняется в stopErr
+func (interp *Interpreter) exceed(d *Diagnostic, stmt Stmt) error {
+	if interp.stopErr == nil {
+		if stmt != nil {
+			d = stmtDiagnostic(d, stmt)
+		}
+		interp.stopErr = d
+	}
+	return errStopped
+}
+
+// учёт очередной инструкции; true - выполнение прекращается: отменено, истекло время
+// или превышено число инструкций
+func (interp *Interpreter) halted(stmt Stmt) bool {
+	interp.steps++
+	if limit := interp.Limits.MaxSteps; limit > 0 && interp.steps > limit {
+		interp.exceed(stepLimit(stmt, limit), stmt)
+	}
+	return interp.interrupted(stmt)
+}
+
+// сброс счётчиков перед выполнением
+func (interp *Interpreter) resetLimits() {
+	interp.steps, interp.stopErr = 0, nil
+}
+
+// присваивание новой переменной в текущей области не должно превышать MaxVariables
+func (interp *Interpreter) defineVariable(s *AssignStmt) error {
+	limit := interp.Limits.MaxVariables
+	if limit > 0 && interp.Variables.Search(s.Name.Text) == nil && interp.Variables.Len() >= limit {
+		return interp.exceed(variableLimit(s.Name.Span, limit), s)
+	}
+	return nil
+}
+
+// первое выражение, вложенное глубже limit уровней; nil - такого нет.
+// Обход не спускается ниже limit+1, поэтому сам не зависит от глубины дерева
+func deepExpr(expr Expr, depth, limit int) Expr {
+	if depth > limit {
+		return expr
+	}
+	var children []Expr
+	switch e := expr.(type) {
+	case *CallExpr:
+		children = e.Args
+	case *FuncCall:
+		children = e.Args
+	case *UnaryExpr:
+		children = []Expr{e.X}
+	case *BinaryExpr:
+		children = []Expr{e.X, e.Y}
+	}
+	for _, child := range children {
+		if deep := deepExpr(child, depth+1, limit); deep != nil {
+			return deep
+		}
+	}
+	return nil
+}
+
+// в режиме big степень и произведение проверяются до вычисления,
+// остальные операции - по результату
+func (interp *Interpreter) valueTooLarge(op string, args []Value) bool {
+	limit := interp.Limits.valueBits()
+	if limit == 0 {
+		return false
+	}
+	x, ok := args[0].(*big.Int)
+	if !ok || len(args) < 2 {
+		return false
+	}
+	y := args[1].(*big.Int)
+	switch op {
+	case "pow":
+		// 0, 1 и -1 в любой степени не растут, остальные x^y занимают больше y*(len(x)-1) бит
+		bits := int64(x.BitLen() - 1)
+		return bits > 0 && (!y.IsInt64() || y.Int64() > int64(limit) || y.Int64()*bits >= int64(limit))
+	case "mult":
+		return x.BitLen()+y.BitLen() > limit+1
+	}
+	return false
+}
+
+func (interp *Interpreter) resultTooLarge(value Value) bool {
+	n, ok := value.(*big.Int)
+	limit := interp.Limits.valueBits()
+	return ok && limit > 0 && n.BitLen() > limit
+}
@@ -0,0 +1,64 @@
+package interp
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimits(t *testing.T) {
+	loop := "i = 1;\nwhile i {\n\ti = 1;\n}\n"
+	tests := []struct {
+		name    string
+		numeric string
+		program string
+		input   string
+		limits  Limits
+		want    error
+	}{
+		{"инструкции", "32", loop, "", Limits{MaxSteps: 100}, ErrStepLimit},
+		{"инструкции обходом дерева", "big", loop, "", Limits{MaxSteps: 100}, ErrStepLimit},
+		{"время", "32", loop, "", Limits{Timeout: 20 * time.Millisecond}, ErrTimeLimit},
+		{"время обходом дерева", "big", loop, "", Limits{Timeout: 20 * time.Millisecond}, ErrTimeLimit},
+		// без MaxValueBits степень ограничивается размером по умолчанию и не выходит за время
+		{"степень со временем", "big", "x = pow(3, 100000000000);\n", "", Limits{Timeout: time.Second}, ErrValueLimit},
+		{"переменные", "32", "a = 1;\nb = 2;\nc = 3;\n", "", Limits{MaxVariables: 2}, ErrVariableLimit},
+		{"параметры функции", "32", "func f(a, b, c) {\n\treturn a;\n}\nx = f(1, 2, 3);\n", "", Limits{MaxVariables: 2}, ErrVariableLimit},
+		{"литерал", "32", "a = 12345;\n", "", Limits{MaxLiteralLen: 3}, ErrLiteralLimit},
+		{"введённое значение", "32", "a = input();\n", "12345\n", Limits{MaxLiteralLen: 3}, ErrLiteralLimit},
+		{"вложенность выражений", "32", "a = add(1, add(1, add(1, add(1, 1))));\n", "", Limits{MaxNesting: 3}, ErrNestingLimit},
+		{"вложенность блоков", "32", "i = 0;\nif i {\n\tif i {\n\t\tif i {\n\t\t\ti = 1;\n\t\t}\n\t}\n}\n", "", Limits{MaxNesting: 2}, ErrNestingLimit},
+		{"размер значения", "big", "a = pow(2, 100);\n", "", Limits{MaxValueBits: 64}, ErrValueLimit},
+	}
+	for _, test := range tests {
+		d := NewDialect()
+		d.Numeric = test.numeric
+		prog, err := CompileWithLimits(d, "test", test.program, test.limits)
+		if err == nil {
+			err = Run(context.Background(), prog, &Env{In: strings.NewReader(test.input), Limits: test.limits})
+		}
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: ожидалась ошибка %q, получено %v", test.name, test.want, err)
+		}
+	}
+}
+
+// в пределах ограничений программа выполняется как обычно
+func TestWithinLimits(t *testing.T) {
+	limits := Limits{MaxSteps: 100, Timeout: time.Second, MaxVariables: 3, MaxLiteralLen: 5, MaxNesting: 4, MaxValueBits: 128}
+	d := NewDialect()
+	d.Numeric = "big"
+	prog, err := CompileWithLimits(d, "test", "a = pow(2, 100);\nb = add(a, 12345);\n", limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := NewVars()
+	if err := Run(context.Background(), prog, &Env{Vars: vars, Limits: limits}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := vars.Get("b"); b == nil || b.String() != "1267650600228229401496703217721" {
+		t.Errorf("b = %v", b)
+	}
+}
@@ -0,0 +1,269 @@
+package interp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Редактор строки для терминала: стрелки, история и дополнение по Tab.
+// Терминал переводится в raw-режим через stty только на время чтения строки.
+type terminalLineReader struct {
+	in       *os.File
+	out      io.Writer
+	complete func(prefix string) []string
+	history  func() []string
+}
+
+// Ввод с терминала, а не из файла или канала
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	_, err = exec.LookPath("stty")
+	return err == nil
+}
+
+func stty(terminal *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = terminal
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (r *terminalLineReader) ReadLine(prompt string) (string, error) {
+	state, err := stty(r.in, "-g")
+	if err != nil {
+		return "", err
+	}
+	if _, err := stty(r.in, "raw", "-echo"); err != nil {
+		return "", err
+	}
+	defer stty(r.in, state)
+
+	e := &lineEditor{reader: r, prompt: prompt}
+	if r.history != nil {
+		e.history = r.history()
+	}
+	e.historyPos = len(e.history)
+	e.redraw()
+	return e.run()
+}
+
+type lineEditor struct {
+	reader     *terminalLineReader
+	prompt     string
+	line       []rune
+	cursor     int
+	history    []string
+	historyPos int
+	pending    []rune // строка, набранная до перехода по истории
+}
+
+func (e *lineEditor) readByte() (byte, error) {
+	var buf [1]byte
+	if _, err := e.reader.in.Read(buf[:]); err != nil {
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// руна из UTF-8 последовательности, начинающейся с first
+func (e *lineEditor) readRune(first byte) (rune, error) {
+	buf := []byte{first}
+	for !utf8.FullRune(buf) {
+		b, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, b)
+	}
+	r, _ := utf8.DecodeRune(buf)
+	return r, nil
+}
+
+func (e *lineEditor) run() (string, error) {
+	for {
+		b, err := e.readByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '\r', '\n':
+			fmt.Fprint(e.reader.out, "\r\n")
+			return string(e.line), nil
+		case 3: // Ctrl-C: сброс строки
+			fmt.Fprint(e.reader.out, "^C\r\n")
+			e.line, e.cursor = nil, 0
+		case 4: // Ctrl-D: конец ввода на пустой строке
+			if len(e.line) == 0 {
+				return "", io.EOF
+			}
+			e.deleteAt(e.cursor)
+		case 127, 8:
+			if e.cursor > 0 {
+				e.cursor--
+				e.deleteAt(e.cursor)
+			}
+		case 1: // Ctrl-A
+			e.cursor = 0
+		case 5: // Ctrl-E
+			e.cursor = len(e.line)
+		case 21: // Ctrl-U
+			e.line, e.cursor = append([]rune(nil), e.line[e.cursor:]...), 0
+		case '\t':
+			e.completeWord()
+		case 27:
+			if err := e.escape(); err != nil {
+				return "", err
+			}
+		default:
+			r, err := e.readRune(b)
+			if err != nil {
+				return "", err
+			}
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+		e.redraw()
+	}
+}
+
+// последовательности ESC [ X: стрелки, Home, End, Delete
+func (e *lineEditor) escape() error {
+	b, err := e.readByte()
+	if err != nil || b != '[' {
+		return err
+	}
+	if b, err = e.readByte(); err != nil {
+		return err
+	}
+	switch b {
+	case 'A':
+		e.moveHistory(-1)
+	case 'B':
+		e.moveHistory(1)
+	case 'C':
+		if e.cursor < len(e.line) {
+			e.cursor++
+		}
+	case 'D':
+		if e.cursor > 0 {
+			e.cursor--
+		}
+	case 'H':
+		e.cursor = 0
+	case 'F':
+		e.cursor = len(e.line)
+	case '3':
+		if b, err = e.readByte(); err == nil && b == '~' {
+			e.deleteAt(e.cursor)
+		}
+	}
+	return err
+}
+
+func (e *lineEditor) insert(runes []rune) {
+	line := append([]rune(nil), e.line[:e.cursor]...)
+	line = append(line, runes...)
+	e.line = append(line, e.line[e.cursor:]...)
+	e.cursor += len(runes)
+}
+
+func (e *lineEditor) deleteAt(i int) {
+	if i < len(e.line) {
+		e.line = append(e.line[:i], e.line[i+1:]...)
+	}
+}
+
+func (e *lineEditor) moveHistory(step int) {
+	pos := e.historyPos + step
+	if pos < 0 || pos > len(e.history) {
+		return
+	}
+	if e.historyPos == len(e.history) {
+		e.pending = e.line
+	}
+	e.historyPos = pos
+	if pos == len(e.history) {
+		e.line = e.pending
+	} else {
+		e.line = []rune(e.history[pos])
+	}
+	e.cursor = len(e.line)
+}
+
+func isWordBreak(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("(),;", r)
+}
+
+// дополнение слова перед курсором; при нескольких вариантах - общий префикс или список
+func (e *lineEditor) completeWord() {
+	if e.reader.complete == nil {
+		return
+	}
+	start := e.cursor
+	for start > 0 && !isWordBreak(e.line[start-1]) {
+		start--
+	}
+	prefix := string(e.line[start:e.cursor])
+	candidates := e.reader.complete(prefix)
+	if len(candidates) == 0 {
+		fmt.Fprint(e.reader.out, "\a")
+		return
+	}
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			_, size := utf8.DecodeLastRuneInString(common)
+			common = common[:len(common)-size]
+		}
+	}
+	if len(common) > len(prefix) {
+		e.insert([]rune(common[len(prefix):]))
+	} else if len(candidates) > 1 {
+		fmt.Fprintf(e.reader.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func (e *lineEditor) redraw() {
+	fmt.Fprintf(e.reader.out, "\r\x1b[K%s%s", e.prompt, string(e.line))
+	if back := len(e.line) - e.cursor; back > 0 {
+		fmt.Fprintf(e.reader.out, "\x1b[%dD", back)
+	}
+}
+
+// Строка из in с приглашением в out: редактор строки, если in - терминал, иначе побайтовое
+// чтение без буферизации, чтобы не забрать ввод, предназначенный для input; false - конец ввода
+func readConsoleLine(in io.Reader, out io.Writer, prompt string, complete func(prefix string) []string) (string, bool) {
+	if file, ok := in.(*os.File); ok && isTerminal(file) {
+		terminal := &terminalLineReader{in: file, out: out, complete: complete}
+		line, err := terminal.ReadLine(prompt)
+		return strings.TrimSpace(line), err == nil
+	}
+	fmt.Fprint(out, prompt)
+	return readRawLine(in)
+}
+
+// строка из in без перевода строки; читается побайтово, чтобы не забрать из in следующие строки.
+// false - ввод закончился до первого символа
+func readRawLine(in io.Reader) (string, bool) {
+	var line []byte
+	var buf [1]byte
+	for {
+		n, err := in.Read(buf[:])
+		if n == 0 || err != nil {
+			return strings.TrimSpace(string(line)), len(line) != 0
+		}
+		if buf[0] == '\n' {
+			return strings.TrimSpace(string(line)), true
+		}
+		line = append(line, buf[0])
+	}
+}
@@ -0,0 +1,378 @@
+package interp
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Коды предупреждений статической проверки
+const (
+	CodeUnusedAssign   = "W001"
+	CodeConstOverflow  = "W002"
+	CodeUnreachable    = "W003"
+	CodeMaybeUndefined = "W004"
+	CodeEndlessLoop    = "W005"
+)
+
+// Статическая проверка программы без выполнения: ошибки разбора, необъявленные переменные,
+// деление на константный ноль, неиспользуемые присваивания, переполнение в константных
+// выражениях, бесконечные циклы и инструкции, до которых выполнение не дойдёт.
+// Тело функции проверяется отдельно: объявлены её параметры и все глобальные переменные.
+// В ветвях if и телах while анализ осторожный: переменная, объявленная не на всех путях,
+// даёт предупреждение, а перезапись значения, присвоенного до ветви, не считается потерей.
+func (interp *Interpreter) Lint(file, program string) Diagnostics {
+	prog, err := interp.Parse(file, program)
+	diags, _ := err.(Diagnostics)
+	l := &linter{interp: interp, defined: make(map[string]bool), pending: make(map[string][]*AssignStmt),
+		funcs: make(map[string]*FuncDecl), globals: make(map[string]bool)}
+	// функции, объявленные раньше (в сеансе REPL), парсер тоже знает
+	for name, decl := range interp.Functions {
+		l.funcs[name] = decl
+	}
+	for _, stmt := range prog.Stmts {
+		if decl, ok := stmt.(*FuncDecl); ok {
+			l.funcs[decl.Name.Text] = decl
+		}
+	}
+	globalAssigns(prog.Stmts, l.globals)
+	l.block(prog.Stmts)
+	l.unused()
+	diags = append(diags, l.diags...)
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Span.Start.Offset < diags[j].Span.Start.Offset
+	})
+	return diags
+}
+
+type linter struct {
+	interp *Interpreter
+	diags  Diagnostics
+	// true - переменная объявлена на всех путях, false - только на некоторых
+	defined map[string]bool
+	// присваивания, значения которых ещё не прочитаны
+	pending map[string][]*AssignStmt
+	// присваивания, сделанные до текущей ветви: их перезапись в ветви не ошибка
+	inherited map[*AssignStmt]bool
+	errors    bool // в заголовке текущей инструкции найдена ошибка
+	stops     bool // текущая инструкция - бесконечный цикл или return
+	// объявленные функции и глобальные переменные, видимые из их тел
+	funcs   map[string]*FuncDecl
+	globals map[string]bool
+}
+
+// имена, которым присваивается значение вне функций
+func globalAssigns(stmts []Stmt, names map[string]bool) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *AssignStmt:
+			names[s.Name.Text] = true
+		case *IfStmt:
+			globalAssigns(s.Then.Stmts, names)
+			if s.Else != nil {
+				globalAssigns(s.Else.Stmts, names)
+			}
+		case *WhileStmt:
+			globalAssigns(s.Body.Stmts, names)
+		}
+	}
+}
+
+// присваивания, значения которых так и не прочитаны
+func (l *linter) unused() {
+	names := make([]string, 0, len(l.pending))
+	for name := range l.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, assign := range l.pending[name] {
+			l.report(SeverityWarning, CodeUnusedAssign, assign.Name.Span, "значение %s нигде не используется", name)
+		}
+	}
+}
+
+// состояние анализа в точке программы
+type lintState struct {
+	defined map[string]bool
+	pending map[string][]*AssignStmt
+}
+
+func (l *linter) save() lintState {
+	state := lintState{make(map[string]bool), make(map[string][]*AssignStmt)}
+	for name, sure := range l.defined {
+		state.defined[name] = sure
+	}
+	for name, writes := range l.pending {
+		state.pending[name] = append([]*AssignStmt(nil), writes...)
+	}
+	return state
+}
+
+func (l *linter) restore(state lintState) {
+	l.defined, l.pending = state.defined, state.pending
+}
+
+// слияние с состоянием другого пути выполнения
+func (l *linter) merge(other lintState) {
+	for name, sure := range other.defined {
+		current, ok := l.defined[name]
+		l.defined[name] = ok && current && sure
+	}
+	for name := range l.defined {
+		if _, ok := other.defined[name]; !ok {
+			l.defined[name] = false
+		}
+	}
+	for name, writes := range other.pending {
+		for _, write := range writes {
+			if !containsAssign(l.pending[name], write) {
+				l.pending[name] = append(l.pending[name], write)
+			}
+		}
+	}
+}
+
+func containsAssign(writes []*AssignStmt, assign *AssignStmt) bool {
+	for _, write := range writes {
+		if write == assign {
+			return true
+		}
+	}
+	return false
+}
+
+// анализ ветви или тела цикла: присваивания до неё помечаются унаследованными
+func (l *linter) branch(stmts []Stmt) {
+	saved := l.inherited
+	l.inherited = make(map[*AssignStmt]bool)
+	for _, writes := range l.pending {
+		for _, write := range writes {
+			l.inherited[write] = true
+		}
+	}
+	l.block(stmts)
+	l.inherited = saved
+}
+
+func (l *linter) report(severity Severity, code string, span Span, format string, args ...interface{}) {
+	d := newDiagnostic(code, span, format, args...)
+	d.Severity = severity
+	l.diags = append(l.diags, d)
+	if severity == SeverityError {
+		l.errors = true
+	}
+}
+
+// инструкции блока по порядку; после ошибки, бесконечного цикла или return остальные недостижимы
+func (l *linter) block(stmts []Stmt) {
+	stopped := 0 // номер инструкции, дальше которой выполнение не идёт
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case *EmptyStmt:
+			continue
+		case *FuncDecl:
+			// функции объявляются до выполнения, их место в программе не важно
+			l.stmt(stmt)
+			continue
+		}
+		if stopped > 0 && !l.interp.KeepGoing {
+			l.report(SeverityWarning, CodeUnreachable, stmtHeader(stmt), "инструкция не выполнится: выполнение не проходит дальше инструкции %d", stopped)
+			stopped = -1 // о недостижимом коде сообщается один раз на блок
+		}
+		l.errors, l.stops = false, false
+		l.stmt(stmt)
+		if (l.errors || l.stops) && stopped == 0 {
+			stopped = stmt.Number()
+		}
+	}
+}
+
+func (l *linter) stmt(stmt Stmt) {
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		if call, ok := s.Value.(*CallExpr); !ok || call.Op.Op != "input" {
+			l.expr(s.Value)
+		}
+		name := s.Name.Text
+		for _, previous := range l.pending[name] {
+			if !l.inherited[previous] {
+				l.report(SeverityWarning, CodeUnusedAssign, previous.Name.Span, "значение %s перезаписывается в строке %d, не будучи прочитанным", name, s.Name.Span.Start.Line)
+			}
+		}
+		l.pending[name] = []*AssignStmt{s}
+		l.defined[name] = true
+	case *ExprStmt:
+		l.expr(s.X)
+	case *IfStmt:
+		l.expr(s.Cond)
+		errors := l.errors
+		before := l.save()
+		l.branch(s.Then.Stmts)
+		after := l.save()
+		l.restore(before)
+		if s.Else != nil {
+			l.branch(s.Else.Stmts)
+		}
+		l.merge(after)
+		l.errors, l.stops = errors, false
+	case *WhileStmt:
+		cond := l.expr(s.Cond)
+		errors := l.errors
+		before := l.save()
+		l.branch(s.Body.Stmts)
+		// значения из тела может прочитать следующая итерация
+		for name := range l.loopReads(s) {
+			delete(l.pending, name)
+		}
+		l.merge(before) // тело может не выполниться ни разу
+		l.errors, l.stops = errors, false
+		if cond != nil && l.interp.Numeric.Compare(cond, l.interp.Numeric.FromInt64(0)) != 0 {
+			l.report(SeverityWarning, CodeEndlessLoop, stmtHeader(s), "условие цикла всегда истинно, цикл не завершится")
+			l.stops = true
+		}
+	case *FuncDecl:
+		outer, inherited := l.save(), l.inherited
+		l.defined, l.pending, l.inherited = make(map[string]bool), make(map[string][]*AssignStmt), nil
+		for name := range l.globals {
+			l.defined[name] = true
+		}
+		for _, param := range s.Params {
+			l.defined[param.Text] = true
+		}
+		l.block(s.Body.Stmts)
+		l.unused() // локальные переменные после выхода из функции не читаются
+		l.restore(outer)
+		l.inherited, l.errors, l.stops = inherited, false, false
+	case *ReturnStmt:
+		l.expr(s.Value)
+		l.stops = true
+	}
+}
+
+// переменные, читаемые в условии и теле цикла
+func (l *linter) loopReads(s *WhileStmt) map[string]bool {
+	reads := make(map[string]bool)
+	visited := make(map[string]bool)
+	l.exprReads(s.Cond, reads, visited)
+	l.stmtReads(s.Body.Stmts, reads, visited)
+	return reads
+}
+
+// имена, читаемые функцией и вызываемыми из неё функциями
+func (l *linter) funcReads(name string) map[string]bool {
+	reads := make(map[string]bool)
+	l.callReads(name, reads, make(map[string]bool))
+	return reads
+}
+
+func (l *linter) callReads(name string, reads, visited map[string]bool) {
+	decl := l.funcs[name]
+	if decl == nil || visited[name] {
+		return
+	}
+	visited[name] = true
+	l.stmtReads(decl.Body.Stmts, reads, visited)
+}
+
+func (l *linter) stmtReads(stmts []Stmt, reads, visited map[string]bool) {
+	walkStmts(stmts, func(stmt Stmt) {
+		if expr := stmtExpr(stmt); expr != nil {
+			l.exprReads(expr, reads, visited)
+		}
+	})
+}
+
+func (l *linter) exprReads(expr Expr, reads, visited map[string]bool) {
+	walkExpr(expr, func(e Expr) {
+		switch e := e.(type) {
+		case *Ident:
+			reads[e.Text] = true
+		case *FuncCall:
+			l.callReads(e.Name.Text, reads, visited)
+		}
+	})
+}
+
+// проверка выражения; возвращает значение, если оно вычисляется без переменных
+func (l *linter) expr(expr Expr) Value {
+	numeric := l.interp.Numeric
+	switch e := expr.(type) {
+	case *NumberLit:
+		value, err := numeric.Parse(e.Text, l.interp.BaseAssign)
+		if err != nil {
+			l.report(SeverityError, CodeBadLiteral, e.Span, "%v", err)
+		}
+		return value
+	case *Ident:
+		delete(l.pending, e.Text)
+		if sure, ok := l.defined[e.Text]; !ok {
+			l.report(SeverityError, CodeUndefinedVar, e.Span, "переменная %s не объявлена", e.Text)
+		} else if !sure {
+			l.report(SeverityWarning, CodeMaybeUndefined, e.Span, "переменная %s объявлена не на всех путях выполнения", e.Text)
+		}
+		return nil
+	case *CallExpr:
+		if e.Op.Op != "output" {
+			l.report(SeverityError, CodeMisplacedIO, e.Span, "%s допустим только в правой части присваивания", e.Op.Text)
+			return nil
+		}
+		l.expr(e.Args[0])
+		return nil
+	case *FuncCall:
+		for _, arg := range e.Args {
+			l.expr(arg)
+		}
+		if l.funcs[e.Name.Text] == nil {
+			l.report(SeverityError, CodeUndefinedFunc, e.Span, "функция %s не объявлена", e.Name.Text)
+			return nil
+		}
+		// функция может прочитать глобальные переменные
+		for name := range l.funcReads(e.Name.Text) {
+			delete(l.pending, name)
+		}
+		return nil
+	case *UnaryExpr:
+		x := l.expr(e.X)
+		if x == nil {
+			return nil
+		}
+		value, _ := numeric.Apply(e.Op.Op, []Value{x})
+		return value
+	case *BinaryExpr:
+		x, y := l.expr(e.X), l.expr(e.Y)
+		if y != nil && (e.Op.Op == "div" || e.Op.Op == "rem") && numeric.Compare(y, numeric.FromInt64(0)) == 0 {
+			l.report(SeverityError, CodeDivisionByZero, e.Span, "деление на ноль в %s", e.Op.Text)
+			return nil
+		}
+		if x == nil || y == nil {
+			return nil
+		}
+		value, err := numeric.Apply(e.Op.Op, []Value{x, y})
+		if err != nil {
+			return nil
+		}
+		l.overflow(e, x, y, value)
+		return value
+	}
+	return nil
+}
+
+// сравнение константной операции с точным результатом: в режимах 32 и 64 значение берётся по модулю 2^bits
+func (l *linter) overflow(e *BinaryExpr, x, y, value Value) {
+	fixed, ok := l.interp.Numeric.(fixedBackend)
+	if !ok {
+		return
+	}
+	if e.Op.Op == "pow" && x.(uint64) > 1 && y.(uint64) >= uint64(fixed.bits) {
+		// точное значение не вычисляется: оно заведомо больше 2^bits, а считать его долго
+		l.report(SeverityWarning, CodeConstOverflow, e.Span, "переполнение %s бит: точное значение не меньше 2^%d, результат %s",
+			fixed.Name(), y.(uint64), fixed.Format(value, 10))
+		return
+	}
+	exact, err := bigBackend{}.Apply(e.Op.Op, []Value{new(big.Int).SetUint64(x.(uint64)), new(big.Int).SetUint64(y.(uint64))})
+	if err != nil || exact.(*big.Int).Cmp(new(big.Int).SetUint64(value.(uint64))) == 0 {
+		return
+	}
+	l.report(SeverityWarning, CodeConstOverflow, e.Span, "переполнение %s бит: точное значение %s, результат %s",
+		fixed.Name(), exact.(*big.Int).String(), fixed.Format(value, 10))
+}
@@ -0,0 +1,62 @@
+package interp
+
+import (
+	"fmt"
+	"testing"
+)
+
+// каждое правило: программа с находкой (и её место) и похожая программа без неё
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		code     string
+		positive string
+		pos      string
+		negative string
+	}{
+		{CodeUndefinedVar, "a = add(b, 1);\noutput(a);\n", "1:9", "b = 1;\na = add(b, 1);\noutput(a);\n"},
+		{CodeMisplacedIO, "a = 1;\noutput(add(input(), a));\n", "2:12", "b = input();\na = 1;\noutput(add(b, a));\n"},
+		{CodeDivisionByZero, "a = 5;\noutput(div(a, 0));\n", "2:8", "a = 5;\noutput(div(a, 1));\n"},
+		{CodeUnusedAssign, "a = 1;\na = 2;\noutput(a);\n", "1:1", "a = 1;\noutput(a);\na = 2;\noutput(a);\n"},
+		{CodeConstOverflow, "a = mult(65536, 65536);\noutput(a);\n", "1:5", "a = mult(65535, 65536);\noutput(a);\n"},
+		{CodeUnreachable, "a = 1;\nwhile 1 {\n\ta = 1;\n}\noutput(a);\n", "5:1", "a = 1;\nwhile a {\n\ta = 0;\n}\noutput(a);\n"},
+		{CodeMaybeUndefined, "a = input();\nif a {\n\tb = 1;\n}\noutput(b);\n", "5:8", "a = input();\nb = 0;\nif a {\n\tb = 1;\n}\noutput(b);\n"},
+		{CodeEndlessLoop, "a = 1;\nwhile 1 {\n\toutput(a);\n}\n", "2:1", "a = 1;\nwhile a {\n\ta = 0;\n}\noutput(a);\n"},
+	}
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	find := func(program, code string) *Diagnostic {
+		for _, d := range interp.Lint("test", program) {
+			if d.Code == code {
+				return d
+			}
+		}
+		return nil
+	}
+	for _, test := range tests {
+		if d := find(test.positive, test.code); d == nil {
+			t.Errorf("%s не найдено в\n%s%v", test.code, test.positive, interp.Lint("test", test.positive))
+		} else if pos := fmt.Sprintf("%d:%d", d.Span.Start.Line, d.Span.Start.Col); pos != test.pos {
+			t.Errorf("%s в %s, ожидалось в %s", test.code, pos, test.pos)
+		}
+		if diags := interp.Lint("test", test.negative); len(diags) != 0 {
+			t.Errorf("%s: лишние находки в\n%s%v", test.code, test.negative, diags)
+		}
+	}
+}
+
+// парсер не пропускает вызов необъявленной функции, а функция из прежнего ввода REPL
+// не считается необъявленной
+func TestLintKnownFunction(t *testing.T) {
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Execute("func f(x) {\n\treturn x;\n}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if diags := interp.Lint("test", "a = f(1);\noutput(a);\n"); len(diags) != 0 {
+		t.Errorf("лишние находки: %v", diags)
+	}
+}
@@ -0,0 +1,559 @@
+package interp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Сервер Language Server Protocol. Синонимы и режимы синтаксиса берутся из файла настроек,
+// поэтому диагностика, подсветка, подсказки и дополнение соответствуют активному диалекту.
+type LSPServer struct {
+	in           *bufio.Reader
+	out          io.Writer
+	opts         Options
+	settingsFile string
+	interp       *Interpreter // nil, если настройки не загрузились
+	docs         map[string]string
+}
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *lspError        `json:"error,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocument struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	Position       lspPosition `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+// Типы семантических токенов в порядке легенды
+var semanticTokenTypes = []string{"operator", "variable", "comment", "number", "macro", "keyword", "function"}
+
+const (
+	semanticOperator = iota
+	semanticVariable
+	semanticComment
+	semanticNumber
+	semanticBreakpoint
+	semanticKeyword
+	semanticFunction
+)
+
+// модификатор declaration - переменная слева от присваивания
+var semanticTokenModifiers = []string{"declaration"}
+
+const lspErrMethodNotFound = -32601
+
+//создание; настройки загружаются после initialize, пустой settingsFile - настроек нет
+func NewLSPServer(in io.Reader, out io.Writer, settingsFile string, opts Options) *LSPServer {
+	return &LSPServer{in: bufio.NewReader(in), out: out, opts: opts, settingsFile: settingsFile, docs: make(map[string]string)}
+}
+
+func (s *LSPServer) send(msg *lspMessage) {
+	msg.JSONRPC = "2.0"
+	body, _ := json.Marshal(msg)
+	writeMessage(s.out, body)
+}
+
+func (s *LSPServer) notify(method string, params interface{}) {
+	raw, _ := json.Marshal(params)
+	s.send(&lspMessage{Method: method, Params: raw})
+}
+
+// Обработка сообщений до exit или конца ввода
+func (s *LSPServer) Serve() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var msg lspMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, rpcErr := s.handle(msg.Method, msg.Params)
+		if msg.ID == nil {
+			continue // уведомление
+		}
+		reply := &lspMessage{ID: msg.ID, Result: result}
+		if rpcErr != nil {
+			reply.Result = nil
+			reply.Error = rpcErr
+		} else if result == nil {
+			reply.Result = json.RawMessage("null")
+		}
+		s.send(reply)
+	}
+}
+
+func (s *LSPServer) handle(method string, params json.RawMessage) (interface{}, *lspError) {
+	var doc lspTextDocument
+	json.Unmarshal(params, &doc)
+	uri := doc.TextDocument.URI
+
+	switch method {
+	case "initialize":
+		var init struct {
+			InitializationOptions struct {
+				Settings string `json:"settings"`
+			} `json:"initializationOptions"`
+		}
+		json.Unmarshal(params, &init)
+		if init.InitializationOptions.Settings != "" {
+			s.settingsFile = init.InitializationOptions.Settings
+		}
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   map[string]interface{}{"openClose": true, "change": 1, "save": true},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]interface{}{},
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string]interface{}{"tokenTypes": semanticTokenTypes, "tokenModifiers": semanticTokenModifiers},
+					"full":   true,
+				},
+			},
+			"serverInfo": map[string]interface{}{"name": "interp"},
+		}, nil
+	case "initialized":
+		s.loadSettings()
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		s.docs[uri] = doc.TextDocument.Text
+		s.publish(uri)
+	case "textDocument/didChange":
+		if n := len(doc.ContentChanges); n > 0 {
+			s.docs[uri] = doc.ContentChanges[n-1].Text
+		}
+		s.publish(uri)
+	case "textDocument/didSave":
+		// изменённые настройки перечитываются, диагностика всех документов обновляется
+		if s.isSettings(uri) {
+			s.loadSettings()
+			for open := range s.docs {
+				s.publish(open)
+			}
+		}
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": []interface{}{}})
+	case "textDocument/semanticTokens/full":
+		return map[string]interface{}{"data": s.semanticTokens(uri)}, nil
+	case "textDocument/hover":
+		return s.hover(uri, doc.Position), nil
+	case "textDocument/definition":
+		return s.definition(uri, doc.Position), nil
+	case "textDocument/completion":
+		return s.completion(uri, doc.Position), nil
+	default:
+		if strings.HasPrefix(method, "$/") {
+			return nil, nil
+		}
+		return nil, &lspError{Code: lspErrMethodNotFound, Message: "метод не поддерживается: " + method}
+	}
+	return nil, nil
+}
+
+// загрузка настроек той же логикой, что и при запуске программы;
+// ошибки показываются сообщением и диагностикой самого файла настроек
+func (s *LSPServer) loadSettings() {
+	if s.settingsFile == "" {
+		s.notify("window/showMessage", map[string]interface{}{"type": 1, "message": "не задан файл настроек: interp lsp <settings_file>"})
+		return
+	}
+	interp, err := NewInterpreterWithOptions(s.settingsFile, s.opts)
+	settingsURI := fileURI(s.settingsFile)
+	diagnostics := []interface{}{}
+	if err != nil {
+		s.notify("window/showMessage", map[string]interface{}{"type": 1, "message": "ошибка в настройках: " + err.Error()})
+		var diags Diagnostics
+		if errors.As(err, &diags) {
+			settings, _ := readFile(s.settingsFile)
+			for _, d := range diags {
+				diagnostics = append(diagnostics, lspDiagnostic(settings, d))
+			}
+		}
+	} else {
+		s.interp = interp
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": settingsURI, "diagnostics": diagnostics})
+}
+
+func fileURI(path string) string {
+	abs, _ := filepath.Abs(path)
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+}
+
+func uriPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		return filepath.FromSlash(u.Path)
+	}
+	return uri
+}
+
+func (s *LSPServer) isSettings(uri string) bool {
+	settings, _ := filepath.Abs(s.settingsFile)
+	return s.settingsFile != "" && uriPath(uri) == settings
+}
+
+// разбор документа в активном диалекте; nil для файла настроек и без настроек
+func (s *LSPServer) parse(uri string) (*Program, error) {
+	if s.interp == nil || s.isSettings(uri) {
+		return nil, nil
+	}
+	return s.interp.Parse(uriPath(uri), s.docs[uri])
+}
+
+func (s *LSPServer) publish(uri string) {
+	if s.isSettings(uri) {
+		return // диагностику настроек публикует loadSettings
+	}
+	diagnostics := []interface{}{}
+	if s.interp != nil {
+		for _, d := range s.interp.Lint(uriPath(uri), s.docs[uri]) {
+			diagnostics = append(diagnostics, lspDiagnostic(s.docs[uri], d))
+		}
+	}
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": diagnostics})
+}
+
+func lspDiagnostic(src string, d *Diagnostic) map[string]interface{} {
+	return map[string]interface{}{
+		"range":    spanRange(src, d.Span),
+		"severity": int(d.Severity) + 1, // Error=1, Warning=2, Information=3
+		"code":     d.Code,
+		"source":   "interp",
+		"message":  d.Message,
+	}
+}
+
+// Позиции LSP: строки с 0, столбцы в единицах UTF-16
+
+func toLSP(src string, offset int) lspPosition {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	return lspPosition{
+		Line:      strings.Count(src[:lineStart], "\n"),
+		Character: len(utf16.Encode([]rune(src[lineStart:offset]))),
+	}
+}
+
+func spanRange(src string, span Span) lspRange {
+	end := span.End.Offset
+	if end <= span.Start.Offset && span.Start.Offset < len(src) {
+		end = span.Start.Offset + 1
+	}
+	return lspRange{Start: toLSP(src, span.Start.Offset), End: toLSP(src, end)}
+}
+
+func fromLSP(src string, pos lspPosition) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		next := strings.IndexByte(src[offset:], '\n')
+		if next < 0 {
+			return len(src)
+		}
+		offset += next + 1
+	}
+	for units := 0; units < pos.Character && offset < len(src) && src[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(src[offset:])
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+// все токены документа, включая комментарии и недопустимые символы
+func (s *LSPServer) tokens(uri string) []Token {
+	if s.interp == nil || s.isSettings(uri) {
+		return nil
+	}
+	var tokens []Token
+	lx := NewLexer(uriPath(uri), s.docs[uri], s.interp.Commands)
+	for tok := lx.Next(); tok.Kind != TokEOF; tok = lx.Next() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// токен, содержащий позицию или заканчивающийся на ней
+func (s *LSPServer) tokenAt(uri string, pos lspPosition) (Token, bool) {
+	offset := fromLSP(s.docs[uri], pos)
+	for _, tok := range s.tokens(uri) {
+		if tok.Span.Start.Offset <= offset && offset <= tok.Span.End.Offset && tok.Kind != TokComment {
+			return tok, true
+		}
+	}
+	return Token{}, false
+}
+
+// первые присваивания переменных документа
+func (s *LSPServer) definitions(uri string) map[string]*Ident {
+	defs := make(map[string]*Ident)
+	prog, _ := s.parse(uri)
+	if prog == nil {
+		return defs
+	}
+	walkStmts(prog.Stmts, func(stmt Stmt) {
+		if assign, ok := stmt.(*AssignStmt); ok && defs[assign.Name.Text] == nil {
+			defs[assign.Name.Text] = assign.Name
+		}
+	})
+	return defs
+}
+
+// функции документа по именам
+func (s *LSPServer) functions(uri string) map[string]*FuncDecl {
+	funcs := make(map[string]*FuncDecl)
+	if prog, _ := s.parse(uri); prog != nil {
+		for _, stmt := range prog.Stmts {
+			if decl, ok := stmt.(*FuncDecl); ok {
+				funcs[decl.Name.Text] = decl
+			}
+		}
+	}
+	return funcs
+}
+
+func (s *LSPServer) semanticTokens(uri string) []int {
+	src := s.docs[uri]
+	declarations := make(map[int]bool)
+	calls := make(map[int]bool) // имена функций в объявлениях и вызовах
+	if prog, _ := s.parse(uri); prog != nil {
+		walkStmts(prog.Stmts, func(stmt Stmt) {
+			switch s := stmt.(type) {
+			case *AssignStmt:
+				declarations[s.Name.Span.Start.Offset] = true
+			case *FuncDecl:
+				declarations[s.Name.Span.Start.Offset] = true
+				calls[s.Name.Span.Start.Offset] = true
+				for _, param := range s.Params {
+					declarations[param.Span.Start.Offset] = true
+				}
+			}
+			if expr := stmtExpr(stmt); expr != nil {
+				walkExpr(expr, func(e Expr) {
+					if call, ok := e.(*FuncCall); ok {
+						calls[call.Name.Span.Start.Offset] = true
+					}
+				})
+			}
+		})
+	}
+
+	var data []int
+	var prev lspPosition
+	add := func(start, end, kind, modifiers int) {
+		from, to := toLSP(src, start), toLSP(src, end)
+		delta := from.Character
+		if from.Line == prev.Line {
+			delta -= prev.Character
+		}
+		data = append(data, from.Line-prev.Line, delta, to.Character-from.Character, kind, modifiers)
+		prev = from
+	}
+	for _, tok := range s.tokens(uri) {
+		start, end := tok.Span.Start.Offset, tok.Span.End.Offset
+		switch tok.Kind {
+		case TokOp, TokAssign:
+			add(start, end, semanticOperator, 0)
+		case TokIdent:
+			modifiers := 0
+			if declarations[start] {
+				modifiers = 1
+			}
+			if calls[start] {
+				add(start, end, semanticFunction, modifiers)
+			} else {
+				add(start, end, semanticVariable, modifiers)
+			}
+		case TokNumber:
+			add(start, end, semanticNumber, 0)
+		case TokKeyword:
+			add(start, end, semanticKeyword, 0)
+		case TokBreakpoint:
+			add(start, end, semanticBreakpoint, 0)
+		case TokComment:
+			// многострочный блок [ ... ] - по токену на строку
+			for start < end {
+				lineEnd := strings.IndexByte(src[start:end], '\n')
+				if lineEnd < 0 {
+					lineEnd = end - start
+				}
+				if lineEnd > 0 {
+					add(start, start+lineEnd, semanticComment, 0)
+				}
+				start += lineEnd + 1
+			}
+		}
+	}
+	if data == nil {
+		data = []int{}
+	}
+	return data
+}
+
+// описание синонима: исходная операция, вид записи, приоритет
+func (s *LSPServer) hover(uri string, pos lspPosition) interface{} {
+	tok, ok := s.tokenAt(uri, pos)
+	if !ok {
+		return nil
+	}
+	var text string
+	switch tok.Kind {
+	case TokOp:
+		syntax := s.interp.Syntax()
+		text = fmt.Sprintf("`%s` — операция **%s**", tok.Text, tok.Op)
+		if isUnaryOp(tok.Op) {
+			text += fmt.Sprintf(", унарная, запись %s", syntax.Unary)
+		} else {
+			assoc := "левоассоциативная"
+			if syntax.RightAssoc[tok.Op] {
+				assoc = "правоассоциативная"
+			}
+			text += fmt.Sprintf(", бинарная, запись %s, приоритет %d, %s", syntax.Binary, syntax.Precedence[tok.Op], assoc)
+		}
+	case TokAssign:
+		text = fmt.Sprintf("`%s` — присваивание, результат %s", tok.Text, s.interp.Result)
+	case TokKeyword:
+		text = fmt.Sprintf("`%s` — ключевое слово **%s**", tok.Text, tok.Op)
+	case TokIdent:
+		if decl := s.functions(uri)[tok.Text]; decl != nil {
+			params := make([]string, len(decl.Params))
+			for i, param := range decl.Params {
+				params[i] = param.Text
+			}
+			text = fmt.Sprintf("функция `%s(%s)`, запись %s, объявлена в строке %d",
+				tok.Text, strings.Join(params, ", "), s.interp.Syntax().callMode(len(params)), decl.Name.Span.Start.Line)
+			break
+		}
+		text = fmt.Sprintf("переменная `%s`", tok.Text)
+		if def := s.definitions(uri)[tok.Text]; def != nil {
+			text += fmt.Sprintf(", первое присваивание в строке %d", def.Span.Start.Line)
+		} else {
+			text += ", не присваивается в этом файле"
+		}
+	case TokNumber:
+		value, err := s.interp.Numeric.Parse(tok.Text, s.interp.BaseAssign)
+		if err != nil {
+			text = fmt.Sprintf("некорректное число в системе счисления %d: %v", s.interp.BaseAssign, err)
+		} else {
+			text = fmt.Sprintf("число в системе счисления %d = %s", s.interp.BaseAssign, s.interp.DecimalToBase(value, 10))
+		}
+	default:
+		return nil
+	}
+	return map[string]interface{}{
+		"contents": map[string]interface{}{"kind": "markdown", "value": text},
+		"range":    spanRange(s.docs[uri], tok.Span),
+	}
+}
+
+// переход к объявлению функции или первому присваиванию переменной
+func (s *LSPServer) definition(uri string, pos lspPosition) interface{} {
+	tok, ok := s.tokenAt(uri, pos)
+	if !ok || tok.Kind != TokIdent {
+		return nil
+	}
+	if decl := s.functions(uri)[tok.Text]; decl != nil {
+		return lspLocation{URI: uri, Range: spanRange(s.docs[uri], decl.Name.Span)}
+	}
+	def := s.definitions(uri)[tok.Text]
+	if def == nil {
+		return nil
+	}
+	return lspLocation{URI: uri, Range: spanRange(s.docs[uri], def.Span)}
+}
+
+// переменные и функции документа и синонимы операций с префиксом слова перед курсором
+func (s *LSPServer) completion(uri string, pos lspPosition) interface{} {
+	items := []map[string]interface{}{}
+	if s.interp == nil || s.isSettings(uri) {
+		return items
+	}
+	src := s.docs[uri]
+	offset := fromLSP(src, pos)
+	start := offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(src[:start])
+		if isWordBreak(r) {
+			break
+		}
+		start -= size
+	}
+	prefix := src[start:offset]
+
+	variables := NewTrie()
+	for name, def := range s.definitions(uri) {
+		variables.Insert(name, def)
+	}
+	synonyms := make(map[string]string)
+	for original, synonym := range s.interp.Commands {
+		synonyms[synonym] = original
+	}
+
+	funcs := s.functions(uri)
+	saved, savedFuncs := s.interp.Variables, s.interp.Functions
+	s.interp.Variables, s.interp.Functions = variables, funcs
+	candidates := s.interp.Complete(prefix)
+	s.interp.Variables, s.interp.Functions = saved, savedFuncs
+
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if original, ok := synonyms[candidate]; ok {
+			items = append(items, map[string]interface{}{"label": candidate, "kind": 24, "detail": "операция " + original})
+		} else if funcs[candidate] != nil {
+			items = append(items, map[string]interface{}{"label": candidate, "kind": 3, "detail": "функция"})
+		} else {
+			items = append(items, map[string]interface{}{"label": candidate, "kind": 6, "detail": "переменная"})
+		}
+	}
+	return items
+}
@@ -0,0 +1,226 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Значение в числовом режиме интерпретатора: uint64 для 32 и 64 бит, *big.Int для big
+type Value interface{}
+
+// Числовой режим: разбор литералов, операции и вывод значений
+type NumericBackend interface {
+	Name() string
+	Parse(text string, base int) (Value, error)
+	FromInt64(n int64) Value
+	Apply(op string, args []Value) (Value, error)
+	Format(v Value, base int) string
+	FormatBinary(v Value) string
+	Compare(a, b Value) int
+}
+
+var errDivisionByZero = errors.New("деление на ноль")
+
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Проверка основания системы счисления
+func CheckBase(name string, base int) error {
+	if base < 2 || base > 36 {
+		return fmt.Errorf("%s: основание %d вне диапазона 2-36", name, base)
+	}
+	return nil
+}
+
+// Проверка цифр числа в системе счисления base
+func checkDigits(text string, base int) error {
+	if text == "" {
+		return errors.New("пустое число")
+	}
+	for _, r := range text {
+		d := strings.IndexRune(digits, unicode.ToUpper(r))
+		if d < 0 || d >= base {
+			return fmt.Errorf("цифра %q недопустима в системе счисления %d", r, base)
+		}
+	}
+	return nil
+}
+
+// Названия режимов для настроек и флага --numeric
+var numericBackends = []string{"32", "64", "big"}
+
+//создание по названию
+func NewNumericBackend(name string) (NumericBackend, error) {
+	switch name {
+	case "32":
+		return fixedBackend{bits: 32, mask: 0xFFFFFFFF}, nil
+	case "64":
+		return fixedBackend{bits: 64, mask: ^uint64(0)}, nil
+	case "big":
+		return bigBackend{}, nil
+	}
+	return nil, fmt.Errorf("неизвестный числовой режим %q, допустимы: %s", name, strings.Join(numericBackends, ", "))
+}
+
+// Беззнаковые числа фиксированной разрядности с переполнением по модулю 2^bits
+type fixedBackend struct {
+	bits uint
+	mask uint64
+}
+
+func (b fixedBackend) Name() string {
+	return strconv.Itoa(int(b.bits))
+}
+
+func (b fixedBackend) Parse(text string, base int) (Value, error) {
+	if err := checkDigits(text, base); err != nil {
+		return nil, err
+	}
+	// число шире режима берётся по модулю 2^bits, как и результаты операций
+	n, _ := new(big.Int).SetString(text, base)
+	return n.And(n, new(big.Int).SetUint64(b.mask)).Uint64(), nil
+}
+
+func (b fixedBackend) FromInt64(n int64) Value {
+	return uint64(n) & b.mask
+}
+
+func (b fixedBackend) Apply(op string, args []Value) (Value, error) {
+	x := args[0].(uint64)
+	if op == "not" {
+		return ^x & b.mask, nil
+	}
+	y := args[1].(uint64)
+
+	switch op {
+	case "add":
+		return (x + y) & b.mask, nil
+	case "mult":
+		return (x * y) & b.mask, nil
+	case "sub":
+		return (x - y) & b.mask, nil
+	case "div":
+		if y == 0 {
+			return nil, errDivisionByZero
+		}
+		return x / y, nil
+	case "rem":
+		if y == 0 {
+			return nil, errDivisionByZero
+		}
+		return x % y, nil
+	case "xor":
+		return x ^ y, nil
+	case "and":
+		return x & y, nil
+	case "or":
+		return x | y, nil
+	case "pow":
+		// возведение в степень по модулю 2^64 без потери точности math.Pow
+		result := uint64(1)
+		for ; y > 0; y >>= 1 {
+			if y&1 == 1 {
+				result *= x
+			}
+			x *= x
+		}
+		return result & b.mask, nil
+	}
+	return nil, fmt.Errorf("неизвестная команда %s", op)
+}
+
+func (b fixedBackend) Format(v Value, base int) string {
+	return strings.ToUpper(strconv.FormatUint(v.(uint64), base))
+}
+
+func (b fixedBackend) FormatBinary(v Value) string {
+	return fmt.Sprintf("%0*b", b.bits, v.(uint64))
+}
+
+func (b fixedBackend) Compare(x, y Value) int {
+	switch a, c := x.(uint64), y.(uint64); {
+	case a < c:
+		return -1
+	case a > c:
+		return 1
+	}
+	return 0
+}
+
+// Целые произвольной точности
+type bigBackend struct{}
+
+func (bigBackend) Name() string {
+	return "big"
+}
+
+func (bigBackend) Parse(text string, base int) (Value, error) {
+	if err := checkDigits(text, base); err != nil {
+		return nil, err
+	}
+	n, _ := new(big.Int).SetString(text, base)
+	return n, nil
+}
+
+func (bigBackend) FromInt64(n int64) Value {
+	return big.NewInt(n)
+}
+
+func (bigBackend) Apply(op string, args []Value) (Value, error) {
+	x := args[0].(*big.Int)
+	if op == "not" {
+		return new(big.Int).Not(x), nil
+	}
+	y := args[1].(*big.Int)
+
+	switch op {
+	case "add":
+		return new(big.Int).Add(x, y), nil
+	case "mult":
+		return new(big.Int).Mul(x, y), nil
+	case "sub":
+		return new(big.Int).Sub(x, y), nil
+	case "div":
+		if y.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		return new(big.Int).Quo(x, y), nil
+	case "rem":
+		if y.Sign() == 0 {
+			return nil, errDivisionByZero
+		}
+		return new(big.Int).Rem(x, y), nil
+	case "xor":
+		return new(big.Int).Xor(x, y), nil
+	case "and":
+		return new(big.Int).And(x, y), nil
+	case "or":
+		return new(big.Int).Or(x, y), nil
+	case "pow":
+		return new(big.Int).Exp(x, y, nil), nil
+	}
+	return nil, fmt.Errorf("неизвестная команда %s", op)
+}
+
+func (bigBackend) Format(v Value, base int) string {
+	return strings.ToUpper(v.(*big.Int).Text(base))
+}
+
+func (bigBackend) FormatBinary(v Value) string {
+	n := v.(*big.Int)
+	bits := new(big.Int).Abs(n).Text(2)
+	if pad := len(bits) % 8; pad != 0 {
+		bits = strings.Repeat("0", 8-pad) + bits
+	}
+	if n.Sign() < 0 {
+		return "-" + bits
+	}
+	return bits
+}
+
+func (bigBackend) Compare(x, y Value) int {
+	return x.(*big.Int).Cmp(y.(*big.Int))
+}
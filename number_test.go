@@ -0,0 +1,114 @@
+package interp
+
+import "testing"
+
+func TestNumericBackends(t *testing.T) {
+	tests := []struct {
+		numeric string
+		op      string
+		args    []string // десятичные аргументы
+		want    string
+	}{
+		{"32", "add", []string{"4294967295", "2"}, "1"},
+		{"32", "sub", []string{"0", "1"}, "4294967295"},
+		{"32", "mult", []string{"65536", "65536"}, "0"},
+		{"32", "pow", []string{"3", "21"}, "1870418611"},
+		{"32", "not", []string{"0"}, "4294967295"},
+		{"32", "div", []string{"7", "2"}, "3"},
+		{"32", "rem", []string{"7", "2"}, "1"},
+		{"32", "xor", []string{"12", "10"}, "6"},
+		{"64", "add", []string{"18446744073709551615", "2"}, "1"},
+		{"64", "sub", []string{"0", "1"}, "18446744073709551615"},
+		{"64", "mult", []string{"4294967296", "4294967296"}, "0"},
+		{"64", "pow", []string{"3", "41"}, "18026252303461234787"},
+		{"64", "not", []string{"0"}, "18446744073709551615"},
+		{"big", "add", []string{"18446744073709551615", "2"}, "18446744073709551617"},
+		{"big", "sub", []string{"0", "1"}, "-1"},
+		{"big", "mult", []string{"4294967296", "4294967296"}, "18446744073709551616"},
+		{"big", "pow", []string{"2", "100"}, "1267650600228229401496703205376"},
+		{"big", "not", []string{"0"}, "-1"},
+		{"big", "div", []string{"-7", "2"}, "-3"},
+		{"big", "and", []string{"12", "10"}, "8"},
+		{"big", "or", []string{"12", "10"}, "14"},
+	}
+	for _, test := range tests {
+		backend, err := NewNumericBackend(test.numeric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var args []Value
+		for _, arg := range test.args {
+			negative := arg[0] == '-'
+			if negative {
+				arg = arg[1:]
+			}
+			value, err := backend.Parse(arg, 10)
+			if err != nil {
+				t.Fatalf("%s: %v", arg, err)
+			}
+			if negative {
+				value, _ = backend.Apply("sub", []Value{backend.FromInt64(0), value})
+			}
+			args = append(args, value)
+		}
+		result, err := backend.Apply(test.op, args)
+		if err != nil {
+			t.Errorf("%s %s%v: %v", test.numeric, test.op, test.args, err)
+		} else if got := backend.Format(result, 10); got != test.want {
+			t.Errorf("%s %s%v = %s, ожидалось %s", test.numeric, test.op, test.args, got, test.want)
+		}
+	}
+}
+
+// литерал шире режима берётся по модулю 2^bits, как результаты операций
+func TestFixedLiteralWraps(t *testing.T) {
+	tests := []struct{ numeric, text, want string }{
+		{"32", "4294967296", "0"},
+		{"32", "223456789098", "118489706"},
+		{"64", "18446744073709551617", "1"},
+		{"big", "18446744073709551617", "18446744073709551617"},
+	}
+	for _, test := range tests {
+		backend, _ := NewNumericBackend(test.numeric)
+		value, err := backend.Parse(test.text, 10)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.numeric, test.text, err)
+		} else if got := backend.Format(value, 10); got != test.want {
+			t.Errorf("%s %s = %s, ожидалось %s", test.numeric, test.text, got, test.want)
+		}
+	}
+}
+
+func TestDecimalToBase(t *testing.T) {
+	tests := []struct {
+		base int
+		want string
+	}{
+		{2, "11111111"},
+		{8, "377"},
+		{10, "255"},
+		{16, "FF"},
+		{36, "73"},
+	}
+	for _, numeric := range numericBackends {
+		d := NewDialect()
+		d.Numeric = numeric
+		interp, err := New(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			if got := interp.DecimalToBase(interp.Numeric.FromInt64(255), test.base); got != test.want {
+				t.Errorf("%s: 255 в системе %d = %s, ожидалось %s", numeric, test.base, got, test.want)
+			}
+			value, err := interp.Numeric.Parse(test.want, test.base)
+			if err != nil || interp.Numeric.Compare(value, interp.Numeric.FromInt64(255)) != 0 {
+				t.Errorf("%s: %s в системе %d не разбирается как 255: %v", numeric, test.want, test.base, err)
+			}
+		}
+	}
+	big, _ := NewNumericBackend("big")
+	if got := big.Format(big.FromInt64(-255), 16); got != "-FF" {
+		t.Errorf("big: -255 в системе 16 = %s, ожидалось -FF", got)
+	}
+}
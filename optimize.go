@@ -0,0 +1,326 @@
+package interp
+
+import (
+	"math/big"
+)
+
+// Итог оптимизации: сколько выражений свёрнуто в константы и сколько присваиваний удалено
+type Optimization struct {
+	Folded  int
+	Removed int
+}
+
+// Оптимизация дерева программы: константные подвыражения вычисляются заранее по правилам
+// числового режима, тождества вида x add 0 и not(not(x)) упрощаются, а присваивания,
+// значение которых не будет прочитано, удаляются. Выражения, вычисление которых даёт ошибку,
+// остаются как есть, чтобы ошибка возникла там же, где и без оптимизации.
+// Удаляются только присваивания без вызовов, ввода-вывода и возможных ошибок; переменные,
+// которые программа не читает, после её выполнения могут остаться незаполненными.
+func (interp *Interpreter) OptimizeProgram(prog *Program) (*Program, Optimization) {
+	o := &optimizer{interp: interp, reads: make(map[string]bool), safeAssigns: make(map[*AssignStmt]bool)}
+	walkStmts(prog.Stmts, func(stmt Stmt) {
+		if expr := stmtExpr(stmt); expr != nil {
+			collectReads(expr, o.reads)
+		}
+	})
+	optimized := *prog
+	optimized.Stmts = o.block(prog.Stmts, make(map[string]bool))
+	return &optimized, o.result
+}
+
+type optimizer struct {
+	interp *Interpreter
+	result Optimization
+	// переменные, читаемые в той части программы, где видны присваивания текущего блока
+	reads map[string]bool
+	// присваивания, которые не выводят, не вызывают функций и не дают ошибок
+	safeAssigns map[*AssignStmt]bool
+	inFunc      bool
+}
+
+func collectReads(expr Expr, reads map[string]bool) {
+	walkExpr(expr, func(e Expr) {
+		if ident, ok := e.(*Ident); ok {
+			reads[ident.Text] = true
+		}
+	})
+}
+
+// инструкции блока; defined - переменные, заведомо заданные к началу блока
+func (o *optimizer) block(stmts []Stmt, defined map[string]bool) []Stmt {
+	optimized := make([]Stmt, 0, len(stmts))
+	for _, stmt := range stmts {
+		optimized = append(optimized, o.stmt(stmt, defined))
+	}
+	result := optimized[:0]
+	for i, stmt := range optimized {
+		if assign, ok := stmt.(*AssignStmt); ok && o.removable(assign, optimized[i+1:]) {
+			o.result.Removed++
+			continue
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// копия инструкции с оптимизированными выражениями и вложенными блоками
+func (o *optimizer) stmt(stmt Stmt, defined map[string]bool) Stmt {
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		copied := *s
+		if call, ok := s.Value.(*CallExpr); !ok || call.Op.Op != "input" {
+			copied.Value, _ = o.expr(s.Value)
+			o.safeAssigns[&copied] = o.safe(copied.Value, defined)
+		}
+		if o.assigns(&copied) {
+			defined[s.Name.Text] = true
+		}
+		return &copied
+	case *ExprStmt:
+		copied := *s
+		copied.X, _ = o.expr(s.X)
+		return &copied
+	case *ReturnStmt:
+		copied := *s
+		copied.Value, _ = o.expr(s.Value)
+		return &copied
+	case *IfStmt:
+		copied := *s
+		copied.Cond, _ = o.expr(s.Cond)
+		copied.Then = o.body(s.Then, defined)
+		if s.Else != nil {
+			copied.Else = o.body(s.Else, defined)
+		}
+		return &copied
+	case *WhileStmt:
+		copied := *s
+		copied.Cond, _ = o.expr(s.Cond)
+		copied.Body = o.body(s.Body, defined)
+		return &copied
+	case *FuncDecl:
+		// локальные переменные читаются только в теле функции
+		reads := o.reads
+		o.reads = make(map[string]bool)
+		walkStmts(s.Body.Stmts, func(stmt Stmt) {
+			if expr := stmtExpr(stmt); expr != nil {
+				collectReads(expr, o.reads)
+			}
+		})
+		params := make(map[string]bool)
+		for _, param := range s.Params {
+			params[param.Text] = true
+		}
+		copied := *s
+		o.inFunc = true
+		copied.Body = &Block{Stmts: o.block(s.Body.Stmts, params), Span: s.Body.Span}
+		o.reads, o.inFunc = reads, false
+		return &copied
+	}
+	return stmt
+}
+
+// вложенный блок: присваивания в нём не делают переменные заданными после него
+func (o *optimizer) body(block *Block, defined map[string]bool) *Block {
+	inner := make(map[string]bool, len(defined))
+	for name := range defined {
+		inner[name] = true
+	}
+	return &Block{Stmts: o.block(block.Stmts, inner), Span: block.Span}
+}
+
+// присваивание можно удалить, если его значение не читается: переменная не читается нигде
+// или до любого чтения перезаписывается в том же блоке
+func (o *optimizer) removable(assign *AssignStmt, rest []Stmt) bool {
+	if !o.safeAssigns[assign] || assign.HasBreakpoint() {
+		return false
+	}
+	name := assign.Name.Text
+	if !o.reads[name] {
+		return true
+	}
+	for _, stmt := range rest {
+		reads, calls := make(map[string]bool), false
+		walkStmts([]Stmt{stmt}, func(stmt Stmt) {
+			if expr := stmtExpr(stmt); expr != nil {
+				collectReads(expr, reads)
+				walkExpr(expr, func(e Expr) {
+					if _, ok := e.(*FuncCall); ok {
+						calls = true
+					}
+				})
+			}
+		})
+		// вызванная функция может прочитать глобальную переменную
+		if reads[name] || calls {
+			return false
+		}
+		switch s := stmt.(type) {
+		case *AssignStmt:
+			// присваивание с ошибкой при --keep-going оставляет прежнее значение
+			if s.Name.Text == name && o.assigns(s) {
+				return true
+			}
+		case *ReturnStmt:
+			return false
+		}
+	}
+	return false
+}
+
+// после присваивания переменная заведомо задана: оно не даёт ошибок,
+// а без --keep-going после ошибки выполнение не продолжается, в функции - тоже
+func (o *optimizer) assigns(s *AssignStmt) bool {
+	return o.safeAssigns[s] || !o.interp.KeepGoing || o.inFunc
+}
+
+// вычисление выражения не выводит, не вызывает функций и не даёт ошибок
+func (o *optimizer) safe(expr Expr, defined map[string]bool) bool {
+	switch e := expr.(type) {
+	case *NumberLit:
+		_, err := o.interp.literal(e)
+		return err == nil
+	case *Ident:
+		return defined[e.Text]
+	case *UnaryExpr:
+		return o.safe(e.X, defined)
+	case *BinaryExpr:
+		if e.Op.Op == "div" || e.Op.Op == "rem" {
+			lit, ok := e.Y.(*NumberLit)
+			if !ok {
+				return false
+			}
+			y, err := o.interp.literal(lit)
+			if err != nil || o.interp.Numeric.Compare(y, o.interp.Numeric.FromInt64(0)) == 0 {
+				return false
+			}
+		}
+		return o.safe(e.X, defined) && o.safe(e.Y, defined)
+	}
+	return false
+}
+
+// оптимизированное выражение и его значение, если оно константное
+func (o *optimizer) expr(expr Expr) (Expr, Value) {
+	switch e := expr.(type) {
+	case *NumberLit:
+		value, err := o.interp.literal(e)
+		if err != nil {
+			return e, nil
+		}
+		return e, value
+	case *UnaryExpr:
+		x, xv := o.expr(e.X)
+		copied := *e
+		copied.X = x
+		if xv != nil {
+			if value, err := o.interp.ExecuteCommand(e.Op.Op, []Value{xv}); err == nil {
+				// значение, которое нельзя записать литералом, ещё может свернуться во внешней операции
+				if lit := o.constant(value, e.Span); lit != nil {
+					return lit, value
+				}
+				return &copied, value
+			}
+		}
+		// not(not(x)) = x и в режимах фиксированной разрядности, и в big
+		if inner, ok := x.(*UnaryExpr); ok && inner.Op.Op == "not" && e.Op.Op == "not" {
+			o.result.Folded++
+			return inner.X, nil
+		}
+		return &copied, nil
+	case *BinaryExpr:
+		x, xv := o.expr(e.X)
+		y, yv := o.expr(e.Y)
+		copied := *e
+		copied.X, copied.Y = x, y
+		if xv != nil && yv != nil && o.foldable(e.Op.Op, xv, yv) {
+			if value, err := o.interp.ExecuteCommand(e.Op.Op, []Value{xv, yv}); err == nil {
+				if lit := o.constant(value, e.Span); lit != nil {
+					return lit, value
+				}
+				return &copied, value
+			}
+		}
+		if simplified := o.identity(e.Op.Op, x, xv, y, yv); simplified != nil {
+			o.result.Folded++
+			return simplified, nil
+		}
+		return &copied, nil
+	case *FuncCall:
+		copied := *e
+		copied.Args = o.exprs(e.Args)
+		return &copied, nil
+	case *CallExpr:
+		// ArgText не меняется: вывод показывает исходную запись аргумента
+		copied := *e
+		copied.Args = o.exprs(e.Args)
+		return &copied, nil
+	}
+	return expr, nil
+}
+
+func (o *optimizer) exprs(exprs []Expr) []Expr {
+	optimized := make([]Expr, len(exprs))
+	for i, expr := range exprs {
+		optimized[i], _ = o.expr(expr)
+	}
+	return optimized
+}
+
+// в режиме big степень считается заранее, только если результат не слишком велик
+func (o *optimizer) foldable(op string, x, y Value) bool {
+	if op != "pow" {
+		return true
+	}
+	bx, ok := x.(*big.Int)
+	if !ok {
+		return true
+	}
+	by := y.(*big.Int)
+	return by.IsInt64() && int64(bx.BitLen())*by.Int64() <= 1<<16
+}
+
+// x add 0, x sub 0, x mult 1, x div 1, x pow 1, x xor 0, x or 0 и симметричные для
+// перестановочных операций; все чтения переменных сохраняются
+func (o *optimizer) identity(op string, x Expr, xv Value, y Expr, yv Value) Expr {
+	is := func(v Value, n int64) bool {
+		return v != nil && o.interp.Numeric.Compare(v, o.interp.Numeric.FromInt64(n)) == 0
+	}
+	switch op {
+	case "add", "xor", "or":
+		if is(yv, 0) {
+			return x
+		}
+		if is(xv, 0) {
+			return y
+		}
+	case "mult":
+		if is(yv, 1) {
+			return x
+		}
+		if is(xv, 1) {
+			return y
+		}
+	case "sub":
+		if is(yv, 0) {
+			return x
+		}
+	case "div", "pow":
+		if is(yv, 1) {
+			return x
+		}
+	}
+	return nil
+}
+
+// литерал со значением value в BaseAssign; nil - значение не записывается литералом
+func (o *optimizer) constant(value Value, span Span) *NumberLit {
+	if o.interp.Numeric.Compare(value, o.interp.Numeric.FromInt64(0)) < 0 {
+		return nil // отрицательное значение not в режиме big
+	}
+	text := o.interp.DecimalToBase(value, o.interp.BaseAssign)
+	if text[0] < '0' || text[0] > '9' {
+		text = "0" + text
+	}
+	o.result.Folded++
+	return &NumberLit{Token{Kind: TokNumber, Text: text, Span: span}}
+}
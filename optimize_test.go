@@ -0,0 +1,21 @@
+package interp
+
+import "testing"
+
+// оптимизация не меняет вывод и ошибки программы
+func TestOptimizePreservesOutput(t *testing.T) {
+	for _, numeric := range numericBackends {
+		for _, test := range equivalenceCases {
+			for _, treeWalk := range []bool{false, true} {
+				for _, keepGoing := range []bool{false, true} {
+					plain, _ := runCase(t, numeric, test.program, test.input, treeWalk, false, keepGoing)
+					optimized, _ := runCase(t, numeric, test.program, test.input, treeWalk, true, keepGoing)
+					if plain != optimized {
+						t.Errorf("%s, режим %s, обход дерева %v, keep-going %v:\nбез оптимизации:\n%s\nс оптимизацией:\n%s",
+							test.name, numeric, treeWalk, keepGoing, plain, optimized)
+					}
+				}
+			}
+		}
+	}
+}
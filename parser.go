@@ -0,0 +1,551 @@
+package interp
+
+import (
+	"strings"
+)
+
+// Параметры синтаксиса диалекта из файла настроек
+type Syntax struct {
+	Unary  string // op() или ()op
+	Binary string // op(), ()op или (op)
+	Result string // left или right
+
+	Precedence map[string]int  // уровни бинарных операций, больше - сильнее
+	RightAssoc map[string]bool // правоассоциативные операции
+}
+
+// приоритеты инфиксных операций в режиме (op): побитовые ниже арифметических
+var defaultPrecedence = map[string]int{
+	"or":   1,
+	"xor":  2,
+	"and":  3,
+	"add":  4,
+	"sub":  4,
+	"mult": 5,
+	"div":  5,
+	"rem":  5,
+	"pow":  6,
+}
+
+func isUnaryOp(op string) bool {
+	return op == "not" || op == "input" || op == "output"
+}
+
+// ключевые слова управляющих инструкций, переименовываются синонимами как операции
+func isKeyword(op string) bool {
+	return op == "if" || op == "else" || op == "while" || op == "func" || op == "return"
+}
+
+// Запись вызова функции с arity аргументами: с одним - как унарной операции,
+// с двумя - как бинарной, с другим числом - op() или ()op, инфиксная невозможна
+func (s Syntax) callMode(arity int) string {
+	switch {
+	case arity == 1:
+		return s.Unary
+	case arity == 2:
+		return s.Binary
+	case s.Binary == "()op":
+		return "()op"
+	}
+	return "op()"
+}
+
+// приоритет инфиксного вызова функции: сильнее всех операций
+func (s Syntax) funcPrecedence() int {
+	max := 0
+	for _, level := range s.Precedence {
+		if level > max {
+			max = level
+		}
+	}
+	return max + 1
+}
+
+//префиксный вызов op(...)
+func (s Syntax) isPrefix(op string) bool {
+	if isUnaryOp(op) {
+		return s.Unary == "op()"
+	}
+	return s.Binary == "op()"
+}
+
+//постфиксный вызов (...)op
+func (s Syntax) isPostfix(op string) bool {
+	if isUnaryOp(op) {
+		return s.Unary == "()op"
+	}
+	return s.Binary == "()op"
+}
+
+// Парсер (рекурсивный спуск)
+type Parser struct {
+	lx         *Lexer
+	syntax     Syntax
+	tok        Token
+	breakpoint bool
+	comments   []Token
+	diags      Diagnostics
+	stmt       int
+	depth      int            // вложенность блоков { }
+	inFunc     bool           // разбирается тело функции
+	funcs      map[string]int // известные функции и число их параметров
+	nesting    int            // вложенность разбираемого выражения
+	// ограничения вложенности и длины литералов, 0 - без ограничения
+	maxNesting int
+	maxLiteral int
+}
+
+// выход из разбора инструкции после ошибки
+type bailout struct{}
+
+//создание
+func NewParser(lx *Lexer, syntax Syntax) *Parser {
+	p := &Parser{lx: lx, syntax: syntax, funcs: make(map[string]int)}
+	p.next()
+	return p
+}
+
+// следующий значимый токен; комментарии и #BREAKPOINT запоминаются
+func (p *Parser) next() {
+	for {
+		p.tok = p.lx.Next()
+		switch p.tok.Kind {
+		case TokComment:
+			p.comments = append(p.comments, p.tok)
+		case TokBreakpoint:
+			p.breakpoint = true
+		default:
+			return
+		}
+	}
+}
+
+func (p *Parser) errorf(code string, span Span, format string, args ...interface{}) {
+	p.fail(newDiagnostic(code, span, format, args...))
+}
+
+func (p *Parser) fail(d *Diagnostic) {
+	d.Stmt = p.stmt
+	p.diags = append(p.diags, d)
+	panic(bailout{})
+}
+
+func (p *Parser) expect(kind TokenKind) Token {
+	tok := p.tok
+	if tok.Kind != kind {
+		p.unexpected(kind.String())
+	}
+	p.next()
+	return tok
+}
+
+func (p *Parser) unexpected(want string) {
+	switch p.tok.Kind {
+	case TokIllegal:
+		d := illegalToken(p.tok)
+		p.errorf(d.Code, d.Span, "%s", d.Message)
+	case TokEOF:
+		p.errorf(CodeSyntax, p.tok.Span, "ожидалось %s, найден конец файла", want)
+	default:
+		p.errorf(CodeSyntax, p.tok.Span, "ожидалось %s, найдено %q", want, p.tok.Text)
+	}
+}
+
+// Разбор всей программы
+func (p *Parser) ParseProgram() (*Program, error) {
+	prog := &Program{File: p.lx.file, Source: p.lx.src}
+	p.scanFunctions()
+	for p.tok.Kind != TokEOF {
+		if stmt := p.statement(); stmt != nil {
+			prog.Stmts = append(prog.Stmts, stmt)
+		}
+	}
+	if p.breakpoint {
+		prog.Stmts = append(prog.Stmts, &EmptyStmt{stmtBase{Span: p.tok.Span, Index: p.stmt + 1, Breakpoint: true}})
+	}
+	prog.Comments = p.comments
+	return prog, p.diags.Err()
+}
+
+// функции программы и число их параметров: вызов может стоять раньше объявления
+func (p *Parser) scanFunctions() {
+	scan := *p.lx
+	scan.pos = Pos{Line: 1, Col: 1}
+	for tok := scan.Next(); tok.Kind != TokEOF; tok = scan.Next() {
+		if tok.Kind != TokKeyword || tok.Op != "func" {
+			continue
+		}
+		name := scan.Next()
+		if name.Kind != TokIdent || scan.Next().Kind != TokLParen {
+			continue
+		}
+		arity := 0
+		for tok = scan.Next(); tok.Kind == TokIdent || tok.Kind == TokComma; tok = scan.Next() {
+			if tok.Kind == TokIdent {
+				arity++
+			}
+		}
+		p.funcs[name.Text] = arity
+	}
+}
+
+// инструкция с восстановлением после ошибки до следующей ;
+func (p *Parser) statement() (stmt Stmt) {
+	depth, inFunc, nesting := p.depth, p.inFunc, p.nesting
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			p.depth, p.inFunc, p.nesting = depth, inFunc, nesting
+			p.skipStatement()
+			p.breakpoint = false
+			stmt = nil
+		}
+	}()
+	p.stmt++
+	return p.parseStmt()
+}
+
+// пропуск инструкции с ошибкой: до ; вне фигурных скобок, до конца пропущенного блока
+// вместе с else или до } объемлющего блока
+func (p *Parser) skipStatement() {
+	open := 0
+	for p.tok.Kind != TokEOF {
+		switch p.tok.Kind {
+		case TokSemicolon:
+			if open == 0 {
+				p.next()
+				return
+			}
+		case TokLBrace:
+			open++
+		case TokRBrace:
+			if open == 0 && p.depth > 0 {
+				return
+			}
+			if open > 0 {
+				if open--; open == 0 {
+					p.next()
+					if p.tok.Kind != TokKeyword || p.tok.Op != "else" {
+						return
+					}
+					continue
+				}
+			}
+		}
+		p.next()
+	}
+}
+
+func (p *Parser) parseStmt() Stmt {
+	if p.tok.Kind == TokSemicolon {
+		empty := &EmptyStmt{stmtBase{Span: p.tok.Span, Index: p.stmt, Breakpoint: p.breakpoint}}
+		p.breakpoint = false
+		p.next()
+		if empty.Breakpoint {
+			return empty
+		}
+		return nil
+	}
+	if p.tok.Kind == TokKeyword {
+		return p.parseControl()
+	}
+
+	lhs := p.parseExpr()
+	var name *Ident
+	var rhs Expr
+	if p.tok.Kind == TokAssign {
+		p.next()
+		rhs = p.parseExpr()
+		target := lhs
+		if p.syntax.Result == "right" {
+			target = rhs
+		}
+		var ok bool
+		if name, ok = target.(*Ident); !ok {
+			p.errorf(CodeAssignTarget, target.Pos(), "ожидалось имя переменной")
+		}
+	}
+
+	base := stmtBase{Span: lhs.Pos(), Index: p.stmt, Breakpoint: p.breakpoint}
+	p.breakpoint = false
+	// последняя инструкция блока может обойтись без ;
+	if p.tok.Kind != TokEOF && (p.tok.Kind != TokRBrace || p.depth == 0) {
+		p.expect(TokSemicolon)
+	}
+
+	if rhs == nil {
+		return &ExprStmt{stmtBase: base, X: lhs}
+	}
+	base.Span = joinSpan(lhs.Pos(), rhs.Pos())
+	value := rhs
+	if p.syntax.Result == "right" {
+		value = lhs
+	}
+	return &AssignStmt{stmtBase: base, Name: name, Value: value}
+}
+
+// if <условие> { ... } [else { ... } | else if ...], while <условие> { ... },
+// func и return; инструкцию с блоком завершает }, ; после неё не нужна
+func (p *Parser) parseControl() Stmt {
+	keyword := p.tok
+	base := stmtBase{Index: p.stmt, Breakpoint: p.breakpoint}
+	p.breakpoint = false
+	p.next()
+	switch keyword.Op {
+	case "if":
+		s := &IfStmt{stmtBase: base, Cond: p.parseExpr()}
+		s.Then = p.parseBlock()
+		s.Span = joinSpan(keyword.Span, s.Then.Span)
+		if p.tok.Kind != TokKeyword || p.tok.Op != "else" {
+			return s
+		}
+		p.next()
+		if p.tok.Kind == TokKeyword && p.tok.Op == "if" {
+			// else if - блок из одной вложенной инструкции
+			p.stmt++
+			nested := p.parseControl()
+			s.Else = &Block{Stmts: []Stmt{nested}, Span: nested.Pos()}
+		} else {
+			s.Else = p.parseBlock()
+		}
+		s.Span = joinSpan(keyword.Span, s.Else.Span)
+		return s
+	case "while":
+		s := &WhileStmt{stmtBase: base, Cond: p.parseExpr()}
+		s.Body = p.parseBlock()
+		s.Span = joinSpan(keyword.Span, s.Body.Span)
+		return s
+	case "func":
+		return p.parseFunc(keyword, base)
+	case "return":
+		if !p.inFunc {
+			p.errorf(CodeSyntax, keyword.Span, "%s вне функции", keyword.Text)
+		}
+		s := &ReturnStmt{stmtBase: base, Value: p.parseExpr()}
+		s.Span = joinSpan(keyword.Span, s.Value.Pos())
+		if p.tok.Kind != TokEOF && p.tok.Kind != TokRBrace {
+			p.expect(TokSemicolon)
+		}
+		return s
+	}
+	p.errorf(CodeSyntax, keyword.Span, "%s без if", keyword.Text)
+	return nil
+}
+
+// func имя(параметры) { ... }; только вне блоков и других функций
+func (p *Parser) parseFunc(keyword Token, base stmtBase) Stmt {
+	if p.depth > 0 {
+		p.errorf(CodeSyntax, keyword.Span, "функцию можно объявить только вне блоков")
+	}
+	name := p.expect(TokIdent)
+	p.expect(TokLParen)
+	var params []*Ident
+	seen := make(map[string]bool)
+	for p.tok.Kind != TokRParen {
+		if len(params) > 0 {
+			p.expect(TokComma)
+		}
+		param := &Ident{p.expect(TokIdent)}
+		if seen[param.Text] {
+			p.errorf(CodeSyntax, param.Span, "параметр %s повторяется", param.Text)
+		}
+		seen[param.Text] = true
+		params = append(params, param)
+	}
+	p.next()
+	p.funcs[name.Text] = len(params)
+
+	p.inFunc = true
+	body := p.parseBlock()
+	p.inFunc = false
+	return &FuncDecl{
+		stmtBase: stmtBase{Span: joinSpan(keyword.Span, body.Span), Index: base.Index, Breakpoint: base.Breakpoint},
+		Name:     &Ident{name},
+		Params:   params,
+		Body:     body,
+	}
+}
+
+// вызов функции с проверкой числа аргументов
+func (p *Parser) call(name Token, args []Expr, span Span) Expr {
+	if want := p.funcs[name.Text]; len(args) != want {
+		p.errorf(CodeArity, span, "%s ожидает аргументов: %d, передано: %d", name.Text, want, len(args))
+	}
+	return &FuncCall{Name: name, Args: args, Span: span}
+}
+
+// блок { инструкции }
+func (p *Parser) parseBlock() *Block {
+	open := p.expect(TokLBrace)
+	p.depth++
+	if p.maxNesting > 0 && p.depth > p.maxNesting {
+		p.fail(nestingLimit(open.Span, p.maxNesting))
+	}
+	block := &Block{}
+	for p.tok.Kind != TokRBrace {
+		if p.tok.Kind == TokEOF {
+			p.unexpected("}")
+		}
+		if stmt := p.statement(); stmt != nil {
+			block.Stmts = append(block.Stmts, stmt)
+		}
+	}
+	p.depth--
+	closing := p.tok
+	if p.breakpoint {
+		// #BREAKPOINT перед } - остановка в конце блока
+		p.stmt++
+		block.Stmts = append(block.Stmts, &EmptyStmt{stmtBase{Span: closing.Span, Index: p.stmt, Breakpoint: true}})
+		p.breakpoint = false
+	}
+	p.next()
+	block.Span = joinSpan(open.Span, closing.Span)
+	return block
+}
+
+// выражение; инфиксные операции есть только в режиме (op)
+func (p *Parser) parseExpr() Expr {
+	// каждый вложенный разбор добавляет уровень дерева, поэтому глубина рекурсии
+	// не превышает ограничения; длинные цепочки операций проверяются по готовому дереву
+	p.nesting++
+	if p.maxNesting > 0 && p.nesting > p.maxNesting {
+		p.fail(nestingLimit(p.tok.Span, p.maxNesting))
+	}
+	var x Expr
+	if p.syntax.Binary != "(op)" {
+		x = p.parseOperand()
+	} else {
+		x = p.parseBinary(1)
+	}
+	p.nesting--
+	if p.maxNesting > 0 && p.nesting == 0 {
+		if deep := deepExpr(x, 1, p.maxNesting); deep != nil {
+			p.fail(nestingLimit(deep.Pos(), p.maxNesting))
+		}
+	}
+	return x
+}
+
+// разбор по приоритетам с учётом ассоциативности
+func (p *Parser) parseBinary(minPrec int) Expr {
+	x := p.parseOperand()
+	for {
+		op := p.tok
+		prec, ok := p.syntax.Precedence[op.Op]
+		// функция двух аргументов в режиме (op) записывается как операция
+		function := op.Kind == TokIdent && p.syntax.callMode(p.funcs[op.Text]) == "(op)"
+		if function {
+			prec, ok = p.syntax.funcPrecedence(), true
+		} else if op.Kind != TokOp {
+			ok = false
+		}
+		if !ok || prec < minPrec {
+			return x
+		}
+		p.next()
+		next := prec + 1
+		if !function && p.syntax.RightAssoc[op.Op] {
+			next = prec
+		}
+		y := p.parseBinary(next)
+		if function {
+			x = &FuncCall{Name: op, Args: []Expr{x, y}, Span: joinSpan(x.Pos(), y.Pos())}
+		} else {
+			x = &BinaryExpr{Op: op, X: x, Y: y, Span: joinSpan(x.Pos(), y.Pos())}
+		}
+	}
+}
+
+// операнд: литерал, переменная, вызов операции или функции op(...) или (...)op, скобки
+func (p *Parser) parseOperand() Expr {
+	switch p.tok.Kind {
+	case TokNumber:
+		if p.maxLiteral > 0 && len(p.tok.Text) > p.maxLiteral {
+			p.fail(literalLimit(p.tok.Span, p.maxLiteral))
+		}
+		lit := &NumberLit{p.tok}
+		p.next()
+		return lit
+	case TokIdent:
+		name := p.tok
+		if arity, ok := p.funcs[name.Text]; ok {
+			if p.syntax.callMode(arity) != "op()" {
+				p.errorf(CodeOperandPlacement, name.Span, "недопустимое расположение операндов и операций")
+			}
+			p.next()
+			args, _, closing := p.parseArgs()
+			return p.call(name, args, joinSpan(name.Span, closing.Span))
+		}
+		p.next()
+		return &Ident{name}
+	case TokOp:
+		op := p.tok
+		if !p.syntax.isPrefix(op.Op) {
+			p.errorf(CodeOperandPlacement, op.Span, "недопустимое расположение операндов и операций")
+		}
+		p.next()
+		args, text, closing := p.parseArgs()
+		return p.apply(op, args, text, joinSpan(op.Span, closing.Span))
+	case TokLParen:
+		open := p.tok
+		args, text, closing := p.parseArgs()
+		if p.tok.Kind == TokOp && p.syntax.isPostfix(p.tok.Op) {
+			op := p.tok
+			p.next()
+			return p.apply(op, args, text, joinSpan(open.Span, op.Span))
+		}
+		if arity, ok := p.funcs[p.tok.Text]; ok && p.tok.Kind == TokIdent && p.syntax.callMode(arity) == "()op" {
+			name := p.tok
+			p.next()
+			return p.call(name, args, joinSpan(open.Span, name.Span))
+		}
+		if len(args) != 1 {
+			p.errorf(CodeOperandPlacement, joinSpan(open.Span, closing.Span), "недопустимое расположение операндов и операций")
+		}
+		return args[0]
+	default:
+		p.unexpected("выражение")
+		return nil
+	}
+}
+
+// аргументы в скобках и их исходный текст
+func (p *Parser) parseArgs() ([]Expr, string, Token) {
+	open := p.expect(TokLParen)
+	var args []Expr
+	if p.tok.Kind != TokRParen {
+		args = append(args, p.parseExpr())
+		for p.tok.Kind == TokComma {
+			p.next()
+			args = append(args, p.parseExpr())
+		}
+	}
+	closing := p.expect(TokRParen)
+	text := strings.TrimSpace(p.lx.src[open.Span.End.Offset:closing.Span.Start.Offset])
+	return args, text, closing
+}
+
+// узел операции с проверкой числа аргументов
+func (p *Parser) apply(op Token, args []Expr, text string, span Span) Expr {
+	want := 2
+	switch op.Op {
+	case "input":
+		want = 0
+	case "output", "not":
+		want = 1
+	}
+	if len(args) != want {
+		p.errorf(CodeArity, span, "%s ожидает аргументов: %d, передано: %d", op.Text, want, len(args))
+	}
+
+	switch op.Op {
+	case "input", "output":
+		return &CallExpr{Op: op, Args: args, ArgText: text, Span: span}
+	case "not":
+		return &UnaryExpr{Op: op, X: args[0], Span: span}
+	default:
+		return &BinaryExpr{Op: op, X: args[0], Y: args[1], Span: span}
+	}
+}
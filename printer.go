@@ -0,0 +1,253 @@
+package interp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Печать синтаксического дерева в заданном диалекте: по инструкции на строку,
+// операции через синонимы диалекта, скобки только там, где их требует разбор.
+// Комментарии печатаются перед инструкцией, внутри которой или перед которой стояли,
+// а комментарий в конце строки остаётся в конце строки.
+type Printer struct {
+	Commands map[string]string
+	Syntax   Syntax
+	// преобразование текста числового литерала; nil - литерал печатается как есть
+	Literal func(text string) (string, error)
+
+	out      strings.Builder
+	err      error
+	line     bool // в текущей строке уже что-то напечатано
+	src      string
+	comments []Token // ещё не напечатанные комментарии
+	last     int     // конец последнего напечатанного фрагмента исходного текста
+}
+
+//создание по действующим настройкам интерпретатора
+func NewPrinter(interp *Interpreter) *Printer {
+	return &Printer{Commands: interp.Commands, Syntax: interp.Syntax()}
+}
+
+func (p *Printer) synonym(op string) string {
+	if synonym := p.Commands[op]; synonym != "" {
+		return synonym
+	}
+	return op
+}
+
+func (p *Printer) newline() {
+	if p.line {
+		p.out.WriteByte('\n')
+		p.line = false
+	}
+}
+
+var blankLinePattern = regexp.MustCompile(`\n[ \t\r]*\n`)
+
+// пустые строки между фрагментами исходного текста сохраняются одной пустой строкой
+func (p *Printer) blankLine(from, to int) {
+	if from > 0 && from < to && blankLinePattern.MatchString(p.src[from:to]) {
+		p.out.WriteByte('\n')
+	}
+}
+
+// комментарий на отдельной строке
+func (p *Printer) comment(comment Token, indent string) {
+	p.newline()
+	p.blankLine(p.last, comment.Span.Start.Offset)
+	p.out.WriteString(indent + comment.Text)
+	p.line = true
+	p.last = comment.Span.End.Offset
+}
+
+// Текст программы
+func (p *Printer) Program(prog *Program) (string, error) {
+	p.out.Reset()
+	p.err, p.line = nil, false
+	p.src, p.comments, p.last = prog.Source, prog.Comments, 0
+	p.block(prog.Stmts, len(prog.Source), "")
+	p.newline()
+	return p.out.String(), p.err
+}
+
+// инструкции блока и комментарии до смещения end
+func (p *Printer) block(stmts []Stmt, end int, indent string) {
+	for i, stmt := range stmts {
+		// комментарии до инструкции и внутри её заголовка
+		for len(p.comments) > 0 && p.comments[0].Span.Start.Offset < stmtHeader(stmt).End.Offset {
+			p.comment(p.comments[0], indent)
+			p.comments = p.comments[1:]
+		}
+		p.newline()
+		p.blankLine(p.last, stmt.Pos().Start.Offset)
+		p.last = stmtHeader(stmt).End.Offset
+		if stmt.HasBreakpoint() {
+			p.out.WriteString(indent + breakpointMarker + "\n")
+		}
+		if _, empty := stmt.(*EmptyStmt); empty {
+			continue
+		}
+		p.out.WriteString(indent)
+		p.statement(stmt, indent)
+		p.line = true
+
+		// комментарии в той же строке после инструкции и до следующей
+		next := end
+		if i+1 < len(stmts) {
+			next = stmts[i+1].Pos().Start.Offset
+		}
+		for len(p.comments) > 0 && p.comments[0].Span.Start.Line == stmt.Pos().End.Line && p.comments[0].Span.Start.Offset < next {
+			p.out.WriteString(" " + p.comments[0].Text)
+			p.last = p.comments[0].Span.End.Offset
+			p.comments = p.comments[1:]
+		}
+	}
+	for len(p.comments) > 0 && p.comments[0].Span.Start.Offset < end {
+		p.comment(p.comments[0], indent)
+		p.comments = p.comments[1:]
+	}
+}
+
+// инструкция; у if, while и func тело печатается с отступом на табуляцию
+func (p *Printer) statement(stmt Stmt, indent string) {
+	switch s := stmt.(type) {
+	case *IfStmt:
+		p.out.WriteString(p.synonym("if") + " " + p.Expr(s.Cond) + " ")
+		p.body(s.Then, indent)
+		if s.Else == nil {
+			return
+		}
+		p.out.WriteString(" " + p.synonym("else") + " ")
+		if nested, ok := s.Else.Stmts[0].(*IfStmt); ok && s.Else.Span == nested.Pos() {
+			p.statement(nested, indent)
+			return
+		}
+		p.body(s.Else, indent)
+	case *WhileStmt:
+		p.out.WriteString(p.synonym("while") + " " + p.Expr(s.Cond) + " ")
+		p.body(s.Body, indent)
+	case *FuncDecl:
+		params := make([]string, len(s.Params))
+		for i, param := range s.Params {
+			params[i] = param.Text
+		}
+		p.out.WriteString(p.synonym("func") + " " + s.Name.Text + "(" + strings.Join(params, ", ") + ") ")
+		p.body(s.Body, indent)
+	default:
+		p.out.WriteString(p.Stmt(stmt) + ";")
+	}
+}
+
+func (p *Printer) body(block *Block, indent string) {
+	p.out.WriteString("{")
+	p.line = true
+	// комментарий в строке { остаётся в ней
+	first := block.Span.End.Offset
+	if len(block.Stmts) > 0 {
+		first = block.Stmts[0].Pos().Start.Offset
+	}
+	for len(p.comments) > 0 && p.comments[0].Span.Start.Line == block.Span.Start.Line && p.comments[0].Span.Start.Offset < first {
+		p.out.WriteString(" " + p.comments[0].Text)
+		p.last = p.comments[0].Span.End.Offset
+		p.comments = p.comments[1:]
+	}
+	p.block(block.Stmts, block.Span.End.Offset, indent+"\t")
+	p.newline()
+	p.out.WriteString(indent + "}")
+	p.last = block.Span.End.Offset
+}
+
+// Текст простой инструкции без ;
+func (p *Printer) Stmt(stmt Stmt) string {
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		name, value := p.Expr(s.Name), p.Expr(s.Value)
+		if p.Syntax.Result == "right" {
+			name, value = value, name
+		}
+		return name + " " + p.synonym("=") + " " + value
+	case *ExprStmt:
+		return p.Expr(s.X)
+	case *ReturnStmt:
+		return p.synonym("return") + " " + p.Expr(s.Value)
+	}
+	return ""
+}
+
+// Текст выражения
+func (p *Printer) Expr(expr Expr) string {
+	switch e := expr.(type) {
+	case *NumberLit:
+		if p.Literal == nil {
+			return e.Text
+		}
+		text, err := p.Literal(e.Text)
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("%s: %v", e.Span, err)
+		}
+		return text
+	case *Ident:
+		return e.Text
+	case *CallExpr:
+		return p.call(p.synonym(e.Op.Op), p.Syntax.Unary, e.Args...)
+	case *UnaryExpr:
+		return p.call(p.synonym(e.Op.Op), p.Syntax.Unary, e.X)
+	case *BinaryExpr:
+		if p.Syntax.Binary != "(op)" {
+			return p.call(p.synonym(e.Op.Op), p.Syntax.Binary, e.X, e.Y)
+		}
+		return p.operand(e, e.X, true) + " " + p.synonym(e.Op.Op) + " " + p.operand(e, e.Y, false)
+	case *FuncCall:
+		mode := p.Syntax.callMode(len(e.Args))
+		if mode != "(op)" {
+			return p.call(e.Name.Text, mode, e.Args...)
+		}
+		return p.operand(e, e.Args[0], true) + " " + e.Name.Text + " " + p.operand(e, e.Args[1], false)
+	}
+	return ""
+}
+
+// запись name(args) или (args)name
+func (p *Printer) call(name, mode string, args ...Expr) string {
+	texts := make([]string, len(args))
+	for i, arg := range args {
+		texts[i] = p.Expr(arg)
+	}
+	list := "(" + strings.Join(texts, ", ") + ")"
+	if mode == "()op" {
+		return list + name
+	}
+	return name + list
+}
+
+// приоритет и правая ассоциативность инфиксной записи; ok=false - запись не инфиксная
+func (p *Printer) infix(expr Expr) (prec int, right, ok bool) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return p.Syntax.Precedence[e.Op.Op], p.Syntax.RightAssoc[e.Op.Op], p.Syntax.Binary == "(op)"
+	case *FuncCall:
+		return p.Syntax.funcPrecedence(), false, p.Syntax.callMode(len(e.Args)) == "(op)"
+	}
+	return 0, false, false
+}
+
+// операнд инфиксной операции, в скобках если без них разбор даст другое дерево
+func (p *Printer) operand(parent, child Expr, left bool) string {
+	text := p.Expr(child)
+	prec, right, ok := p.infix(child)
+	if !ok {
+		return text
+	}
+	outer, outerRight, _ := p.infix(parent)
+	switch {
+	case prec > outer:
+		return text
+	case prec == outer:
+		// при равном приоритете без скобок можно только по направлению ассоциативности обеих операций
+		if (left && !right && !outerRight) || (!left && right && outerRight) {
+			return text
+		}
+	}
+	return "(" + text + ")"
+}
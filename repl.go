@@ -0,0 +1,296 @@
+package interp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	replPrompt       = "> "
+	replContinuation = "... "
+	replHistoryLimit = 1000
+	replFile         = "<repl>"
+)
+
+// Источник строк для REPL
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// построчное чтение без редактирования
+type plainLineReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r *plainLineReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := r.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Интерактивный режим: инструкции накапливаются до ;
+type Repl struct {
+	interp      *Interpreter
+	reader      LineReader
+	out         io.Writer
+	buffer      strings.Builder
+	history     []string
+	historyFile string
+}
+
+//создание
+func NewRepl(interp *Interpreter, reader LineReader, out io.Writer) *Repl {
+	repl := &Repl{interp: interp, reader: reader, out: out}
+	if home, err := os.UserHomeDir(); err == nil {
+		repl.historyFile = filepath.Join(home, ".interp_history")
+		repl.loadHistory()
+	}
+	return repl
+}
+
+//REPL на stdin/stdout: на терминале - с редактированием строки, историей и дополнением
+func NewConsoleRepl(interp *Interpreter) *Repl {
+	stdin := bufio.NewReader(os.Stdin)
+	var reader LineReader = &plainLineReader{in: stdin, out: os.Stdout}
+	terminal := &terminalLineReader{in: os.Stdin, out: os.Stdout, complete: interp.Complete}
+	if isTerminal(os.Stdin) {
+		reader = terminal
+	} else if interp.In == os.Stdin {
+		// input читает значения из того же буфера, что и строки программы
+		interp.In = stdin
+	}
+	repl := NewRepl(interp, reader, os.Stdout)
+	terminal.history = repl.History
+	return repl
+}
+
+func (r *Repl) loadHistory() {
+	data, err := os.ReadFile(r.historyFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+}
+
+// История ввода, от старых записей к новым
+func (r *Repl) History() []string {
+	return r.history
+}
+
+// запись в историю и в файл истории
+func (r *Repl) remember(entry string) {
+	entry = strings.Join(strings.Fields(entry), " ")
+	if entry == "" || (len(r.history) > 0 && r.history[len(r.history)-1] == entry) {
+		return
+	}
+	r.history = append(r.history, entry)
+	if len(r.history) > replHistoryLimit {
+		r.history = r.history[len(r.history)-replHistoryLimit:]
+	}
+	if r.historyFile != "" {
+		os.WriteFile(r.historyFile, []byte(strings.Join(r.history, "\n")+"\n"), 0o600)
+	}
+}
+
+// Цикл чтения и выполнения до конца ввода или :quit
+func (r *Repl) Run() error {
+	fmt.Fprintln(r.out, "Введите :help для списка команд")
+	for {
+		prompt := replPrompt
+		if r.buffer.Len() != 0 {
+			prompt = replContinuation
+		}
+		line, err := r.reader.ReadLine(prompt)
+		if err == io.EOF {
+			fmt.Fprintln(r.out)
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if r.buffer.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			r.remember(line)
+			if quit := r.meta(strings.Fields(strings.TrimSpace(line))); quit {
+				return nil
+			}
+			continue
+		}
+
+		r.buffer.WriteString(line)
+		r.buffer.WriteByte('\n')
+		r.flush()
+	}
+}
+
+// выполнение всех завершённых ; инструкций из буфера
+func (r *Repl) flush() {
+	src := r.buffer.String()
+	tokens, err := NewLexer(replFile, src, r.interp.Commands).All()
+	var illegal *Diagnostic
+	unclosed := errors.As(err, &illegal) && illegal.Code == CodeUnclosedComment
+
+	end := -1
+	significant := false
+	depth := 0 // ; внутри блока { } инструкцию не завершает
+	for _, tok := range tokens {
+		switch tok.Kind {
+		case TokSemicolon:
+			if depth == 0 {
+				end = tok.Span.End.Offset
+				significant = false
+				continue
+			}
+		case TokComment, TokEOF:
+			continue
+		case TokLBrace:
+			depth++
+		case TokRBrace:
+			depth--
+		}
+		significant = true
+	}
+	// пустая строка завершает if/while без ; (после } может идти else)
+	if significant && depth <= 0 && err == nil && strings.HasSuffix(src, "\n\n") {
+		end, significant = len(src), false
+	}
+	if end < 0 {
+		if err == nil || unclosed {
+			return // ждём ; или закрывающую ]
+		}
+		end = len(src)
+	}
+
+	chunk := src[:end]
+	r.buffer.Reset()
+	if significant {
+		r.buffer.WriteString(src[end:])
+	}
+	r.remember(chunk)
+	r.eval(chunk)
+}
+
+// выполнение инструкций с выводом результатов в BaseOutput
+func (r *Repl) eval(src string) {
+	prog, err := r.interp.Parse(replFile, src)
+	if err != nil {
+		r.report(err, src)
+		return
+	}
+	for _, stmt := range prog.Stmts {
+		var diags Diagnostics
+		// ограничения действуют на каждую введённую инструкцию отдельно
+		r.interp.resetLimits()
+		r.interp.current = stmt
+		switch stmt.(type) {
+		case *IfStmt, *WhileStmt, *FuncDecl:
+			r.interp.executeBlock([]Stmt{stmt}, &diags)
+		default:
+			if err := r.evalStatement(stmt); err != nil {
+				diags = Diagnostics{stmtDiagnostic(err, stmt)}
+			}
+		}
+		var limit *Diagnostic
+		if errors.As(r.interp.stopErr, &limit) {
+			diags = Diagnostics{limit}
+		}
+		if len(diags) > 0 {
+			r.report(diags, src)
+			return
+		}
+	}
+}
+
+// вывод ошибки, диагностики - с исходными строками
+func (r *Repl) report(err error, src string) {
+	var diags Diagnostics
+	if errors.As(err, &diags) {
+		fmt.Fprint(r.out, diags.Render(src))
+	} else {
+		fmt.Fprintln(r.out, err)
+	}
+}
+
+func (r *Repl) evalStatement(stmt Stmt) error {
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		if err := r.interp.ExecuteStatement(s); err != nil {
+			return err
+		}
+		value := r.interp.Variables.Search(s.Name.Text)
+		fmt.Fprintf(r.out, "%s = %s\n", s.Name.Text, r.interp.DecimalToBase(value, r.interp.BaseOutput))
+	case *ExprStmt:
+		value, err := r.interp.EvaluateExpression(s.X)
+		if err != nil {
+			return err
+		}
+		if call, ok := s.X.(*CallExpr); !ok || call.Op.Op != "output" {
+			fmt.Fprintln(r.out, r.interp.DecimalToBase(value, r.interp.BaseOutput))
+		}
+	}
+	return nil
+}
+
+// мета-команда; true - выход из REPL
+func (r *Repl) meta(args []string) bool {
+	switch args[0] {
+	case ":help":
+		fmt.Fprintln(r.out, ":vars          - все переменные")
+		fmt.Fprintln(r.out, ":settings      - действующие настройки")
+		fmt.Fprintln(r.out, ":reset         - удалить переменные, функции, точки останова и наблюдения")
+		fmt.Fprintln(r.out, ":load <файл>   - выполнить программу из файла")
+		fmt.Fprintln(r.out, ":history       - история ввода")
+		fmt.Fprintln(r.out, ":quit          - выход")
+		fmt.Fprintln(r.out, "if, while и func без ; после } выполняются после пустой строки")
+	case ":vars":
+		names := r.interp.Variables.ObtainAll()
+		sort.Strings(names)
+		for _, name := range names {
+			value := r.interp.Variables.Search(name)
+			fmt.Fprintf(r.out, "%s = %s\n", name, r.interp.DecimalToBase(value, r.interp.BaseOutput))
+		}
+	case ":settings":
+		r.interp.CurrentDialect().Write(r.out, "txt")
+	case ":reset":
+		r.interp.Reset()
+		fmt.Fprintln(r.out, "Переменные, функции, точки останова и наблюдения удалены")
+	case ":load":
+		if len(args) != 2 {
+			fmt.Fprintln(r.out, "Использование: :load <файл>")
+			break
+		}
+		program, err := readFile(args[1])
+		if err != nil {
+			fmt.Fprintln(r.out, err)
+			break
+		}
+		programFile := r.interp.ProgramFile
+		r.interp.ProgramFile = args[1]
+		if err := r.interp.Execute(program); err != nil {
+			r.report(err, program)
+		}
+		r.interp.ProgramFile = programFile
+	case ":history":
+		for i, entry := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, entry)
+		}
+	case ":quit", ":q":
+		return true
+	default:
+		fmt.Fprintf(r.out, "Неизвестная команда %s, :help - список команд\n", args[0])
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package interp
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// :reset удаляет не только переменные: функцию можно объявить заново
+func TestReplReset(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	interp, err := New(NewDialect())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp.Out = &out
+	interp.Breakpoints = []*Breakpoint{{Line: 1}}
+	input := `func f(x) {
+	return x;
+}
+func g(x) {
+	return x;
+}
+
+y = f(1);
+:reset
+func f(a, b) {
+	return add(a, b);
+}
+
+z = f(1, 2);
+:vars
+`
+	repl := NewRepl(interp, &plainLineReader{in: bufio.NewReader(strings.NewReader(input)), out: &out}, &out)
+	if err := repl.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "ошибка") {
+		t.Errorf("ошибка после :reset:\n%s", out.String())
+	}
+	if !strings.HasSuffix(out.String(), "> z = 3\n> z = 3\n> \n") {
+		t.Errorf(":vars после :reset:\n%s", out.String())
+	}
+	if interp.Variables.Search("y") != nil || len(interp.Functions) != 1 || len(interp.Functions["f"].Params) != 2 || interp.Breakpoints != nil {
+		t.Error("переменные, функции или точки останова не сброшены")
+	}
+}
@@ -0,0 +1,174 @@
+// Пакет interp - интерпретатор языка с настраиваемым диалектом: синонимы команд,
+// синтаксис операций, основания систем счисления и числовой режим задаются файлом настроек.
+//
+// Для встраивания программа компилируется один раз и выполняется через Run
+// со своими вводом, выводом и переменными:
+//
+//	dialect, err := interp.ParseSettings("settings.txt", settings)
+//	prog, err := interp.Compile(dialect, "main.prog", source)
+//	vars := interp.NewVars()
+//	vars.SetUint64("x", 42)
+//	err = interp.Run(ctx, prog, &interp.Env{In: in, Out: out, Vars: vars})
+package interp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+var errNotCompiled = errors.New("программа не скомпилирована: используйте Compile")
+
+// Разбор программы в диалекте d и компиляция в байт-код, если числовой режим его
+// поддерживает; в режиме big программа выполняется обходом дерева
+func Compile(d *Dialect, file, source string) (*Program, error) {
+	return CompileWithLimits(d, file, source, Limits{})
+}
+
+// Compile с ограничениями вложенности и длины литералов для недоверенных программ
+func CompileWithLimits(d *Dialect, file, source string, limits Limits) (*Program, error) {
+	interp, err := New(d)
+	if err != nil {
+		return nil, err
+	}
+	interp.Limits = limits
+	prog, err := interp.Parse(file, source)
+	if err != nil {
+		return nil, err
+	}
+	interp.declareFunctions(prog.Stmts)
+	// копия диалекта: последующие изменения d не влияют на программу
+	prog.dialect = interp.CurrentDialect()
+	prog.code, _ = interp.Compile(prog)
+	return prog, nil
+}
+
+// Окружение выполнения программы
+type Env struct {
+	In  io.Reader // ввод для input; nil - ввода нет
+	Out io.Writer // вывод output; nil - вывод отбрасывается
+	// приглашения "Enter value for ..." перед вводом; nil - без приглашений
+	Prompt io.Writer
+	// глобальные переменные: начальные значения и результат выполнения; nil - пустой набор
+	Vars         *Vars
+	KeepGoing    bool
+	MaxCallDepth int // 0 - defaultMaxCallDepth
+	// ограничения выполнения; ошибки превышения проверяются errors.Is(err, ErrStepLimit) и т. п.
+	Limits Limits
+}
+
+// Выполнение программы, скомпилированной Compile. Каждый запуск получает свой интерпретатор,
+// поэтому одну программу можно выполнять одновременно с разными окружениями.
+// После отмены ctx выполнение прерывается и возвращается ошибка контекста,
+// после превышения ограничения из env.Limits - Diagnostics с диагностикой превышения
+func Run(ctx context.Context, prog *Program, env *Env) error {
+	if prog.dialect == nil {
+		return errNotCompiled
+	}
+	if env == nil {
+		env = &Env{}
+	}
+	interp, err := New(prog.dialect)
+	if err != nil {
+		return err
+	}
+	if env.In != nil {
+		interp.In = env.In
+	}
+	if env.Out != nil {
+		interp.Out = env.Out
+	}
+	interp.Prompt = env.Prompt
+	interp.KeepGoing, interp.MaxCallDepth, interp.Limits = env.KeepGoing, env.MaxCallDepth, env.Limits
+	if env.Vars != nil {
+		if err := env.Vars.load(interp); err != nil {
+			return err
+		}
+	}
+	err = interp.run(ctx, prog, prog.code, nil)
+	if env.Vars != nil {
+		env.Vars.store(interp)
+	}
+	return err
+}
+
+// Переменные для Run. Значения хранятся целыми произвольной длины и при запуске
+// приводятся к числовому режиму программы
+type Vars struct {
+	trie *Trie
+}
+
+func NewVars() *Vars {
+	return &Vars{trie: NewTrie()}
+}
+
+// значение переменной; false - переменная не задана
+func (v *Vars) Get(name string) (*big.Int, bool) {
+	value, ok := v.trie.Search(name).(*big.Int)
+	if !ok {
+		return nil, false
+	}
+	return new(big.Int).Set(value), true
+}
+
+// значение переменной, если оно задано и помещается в uint64
+func (v *Vars) Uint64(name string) (uint64, bool) {
+	value, ok := v.Get(name)
+	if !ok || !value.IsUint64() {
+		return 0, false
+	}
+	return value.Uint64(), true
+}
+
+func (v *Vars) Set(name string, value *big.Int) {
+	v.trie.Insert(name, new(big.Int).Set(value))
+}
+
+func (v *Vars) SetUint64(name string, value uint64) {
+	v.trie.Insert(name, new(big.Int).SetUint64(value))
+}
+
+func (v *Vars) Delete(name string) {
+	v.trie.Delete(name)
+}
+
+// имена заданных переменных по возрастанию
+func (v *Vars) Names() []string {
+	var names []string
+	for _, name := range v.trie.KeysWithPrefix("") {
+		if _, ok := v.Get(name); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// перенос значений в переменные интерпретатора
+func (v *Vars) load(interp *Interpreter) error {
+	for _, name := range v.Names() {
+		value, _ := v.Get(name)
+		var converted Value = value
+		if fixed, ok := interp.Numeric.(fixedBackend); ok {
+			if value.Sign() < 0 || value.BitLen() > int(fixed.bits) {
+				return fmt.Errorf("значение переменной %s не помещается в %d бит", name, fixed.bits)
+			}
+			converted = value.Uint64()
+		}
+		interp.Variables.Insert(name, converted)
+	}
+	return nil
+}
+
+// значения переменных интерпретатора после выполнения
+func (v *Vars) store(interp *Interpreter) {
+	for _, name := range interp.Variables.KeysWithPrefix("") {
+		switch value := interp.Variables.Search(name).(type) {
+		case uint64:
+			v.SetUint64(name, value)
+		case *big.Int:
+			v.Set(name, value)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package interp
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunInputOutput(t *testing.T) {
+	prog, err := Compile(NewDialect(), "test", "a = input();\nb = input();\noutput(add(a, b));\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		input  string
+		prompt bool
+		want   string
+		err    string
+	}{
+		{"значения", "2\n3\n", false, "add(a, b) = 5\n", ""},
+		{"без перевода строки в конце", "2\n3", false, "add(a, b) = 5\n", ""},
+		{"приглашения", "2\n3\n", true, "add(a, b) = 5\n", ""},
+		{"ввод закончился", "2\n", false, "", "нет значения для b: ввод закончился"},
+		{"два значения в строке", "2 3\n4\n", false, "", "ожидалось одно число"},
+		{"пустая строка", "2\n\n", false, "", "пустое число"},
+	}
+	for _, test := range tests {
+		var out, prompt bytes.Buffer
+		env := &Env{In: strings.NewReader(test.input), Out: &out}
+		if test.prompt {
+			env.Prompt = &prompt
+		}
+		err := Run(context.Background(), prog, env)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%s: ошибка %v, ожидалось %q", test.name, err, test.err)
+		}
+		if out.String() != test.want {
+			t.Errorf("%s: вывод %q, ожидалось %q", test.name, out.String(), test.want)
+		}
+		if want := "Enter value for a: Enter value for b: "; test.prompt && prompt.String() != want {
+			t.Errorf("%s: приглашения %q, ожидалось %q", test.name, prompt.String(), want)
+		}
+	}
+}
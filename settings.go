@@ -0,0 +1,466 @@
+package interp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Поддерживаемая версия формата файла настроек
+const settingsVersion = 1
+
+// Коды ошибок файла настроек
+const (
+	CodeSettingsUnknown   = "S001"
+	CodeSettingsSynonym   = "S002"
+	CodeSettingsCollision = "S003"
+	CodeSettingsConflict  = "S004"
+	CodeSettingsValue     = "S005"
+	CodeSettingsComment   = "S006"
+	CodeSettingsVersion   = "S007"
+)
+
+// Исходные имена команд
+var commandNames = []string{
+	"not", "input", "output", "add", "mult", "sub", "pow",
+	"div", "rem", "xor", "and", "or", "=",
+	"if", "else", "while", "func", "return",
+}
+
+// символы, которые синоним содержать не может: на них опирается лексер
+const forbiddenSynonymChars = "();#,[]{}"
+
+// Диалект: синонимы команд и синтаксис из файла настроек
+type Dialect struct {
+	Version      int
+	Commands     map[string]string
+	Result       string
+	UnarySyntax  string
+	BinarySyntax string
+	Precedence   map[string]int
+	RightAssoc   map[string]bool
+	Numeric      string
+	BaseInput    int // 0 - не задано в настройках
+	BaseOutput   int
+	BaseAssign   int
+}
+
+//создание с настройками по умолчанию
+func NewDialect() *Dialect {
+	d := &Dialect{
+		Version:      settingsVersion,
+		Commands:     make(map[string]string),
+		Result:       "left",
+		UnarySyntax:  "op()",
+		BinarySyntax: "op()",
+		Precedence:   make(map[string]int),
+		RightAssoc:   map[string]bool{"pow": true},
+		Numeric:      "32",
+	}
+	for _, name := range commandNames {
+		d.Commands[name] = name
+	}
+	for op, level := range defaultPrecedence {
+		d.Precedence[op] = level
+	}
+	return d
+}
+
+func isCommandName(name string) bool {
+	for _, command := range commandNames {
+		if command == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Загрузка файла настроек, формат определяется по расширению
+func LoadDialect(path string) (*Dialect, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch settingsFormat(path) {
+	case "json":
+		return ParseJSONSettings(path, data)
+	case "toml":
+		return ParseTOMLSettings(path, string(data))
+	default:
+		return ParseSettings(path, string(data))
+	}
+}
+
+// формат файла настроек по расширению: txt, json или toml
+func settingsFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".toml":
+		return "toml"
+	default:
+		return "txt"
+	}
+}
+
+// значение директивы и где оно задано
+type setting struct {
+	value string
+	span  Span
+}
+
+type settingsParser struct {
+	file     string
+	dialect  *Dialect
+	diags    Diagnostics
+	synonyms map[string]Span // исходное имя -> где задан синоним
+	owners   map[string]string
+	version  *setting
+	result   *setting
+	unary    *setting
+	binary   *setting
+	seen     bool
+}
+
+// Разбор файла настроек; ошибки возвращаются как Diagnostics с номерами строк
+func ParseSettings(file, src string) (*Dialect, error) {
+	p := newSettingsParser(file)
+	for i, line := range strings.Split(p.stripComments(src), "\n") {
+		p.line(i+1, line)
+	}
+	return p.finish()
+}
+
+func newSettingsParser(file string) *settingsParser {
+	return &settingsParser{
+		file:     file,
+		dialect:  NewDialect(),
+		synonyms: make(map[string]Span),
+		owners:   make(map[string]string),
+	}
+}
+
+func (p *settingsParser) finish() (*Dialect, error) {
+	if p.result != nil {
+		p.dialect.Result = p.result.value
+	}
+	if p.unary != nil {
+		p.dialect.UnarySyntax = p.unary.value
+		p.dialect.BinarySyntax = p.unary.value
+	}
+	if p.binary != nil {
+		p.dialect.BinarySyntax = p.binary.value
+	}
+	return p.dialect, p.diags.Err()
+}
+
+func (p *settingsParser) span(line, col int, text string) Span {
+	start := Pos{Line: line, Col: col}
+	end := Pos{Line: line, Col: col + len([]rune(text))}
+	return Span{File: p.file, Start: start, End: end}
+}
+
+func (p *settingsParser) errorf(code string, span Span, format string, args ...interface{}) {
+	p.diags = append(p.diags, newDiagnostic(code, span, format, args...))
+}
+
+// комментарии # и вложенные [ ] заменяются пробелами, строки и столбцы сохраняются
+func (p *settingsParser) stripComments(src string) string {
+	var sb strings.Builder
+	var open []Pos
+	line, col := 1, 1
+	inLine := false
+	for _, r := range src {
+		blank := inLine || len(open) > 0
+		switch {
+		case r == '\n':
+			inLine = false
+			blank = false
+		case inLine:
+		case r == '#' && len(open) == 0:
+			inLine = true
+			blank = true
+		case r == '[':
+			open = append(open, Pos{Line: line, Col: col})
+			blank = true
+		case r == ']':
+			if len(open) == 0 {
+				p.errorf(CodeSettingsComment, p.span(line, col, "]"), "лишняя закрывающая ]")
+			} else {
+				open = open[:len(open)-1]
+			}
+			blank = true
+		}
+		if blank {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteRune(r)
+		}
+		if r == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	for _, pos := range open {
+		p.errorf(CodeSettingsComment, p.span(pos.Line, pos.Col, "["), "незакрытый блок комментария")
+	}
+	return sb.String()
+}
+
+// слово строки и его столбец
+type settingsField struct {
+	text string
+	span Span
+}
+
+func (p *settingsParser) fields(line int, text string) []settingsField {
+	var fields []settingsField
+	start := -1
+	runes := []rune(text)
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			word := string(runes[start:i])
+			fields = append(fields, settingsField{word, p.span(line, start+1, word)})
+			start = -1
+		}
+	}
+	return fields
+}
+
+func (p *settingsParser) line(number int, text string) {
+	fields := p.fields(number, text)
+	if len(fields) != 0 {
+		p.directive(fields[0], fields[1:])
+	}
+}
+
+// директива с аргументами; JSON и TOML сводятся к тем же директивам
+func (p *settingsParser) directive(first settingsField, args []settingsField) {
+	if first.text != "version" {
+		p.seen = true
+	}
+
+	switch first.text {
+	case "version":
+		p.versionDirective(first, args)
+	case "left=", "right=":
+		if p.noArgs(first, args) {
+			p.set(&p.result, strings.TrimSuffix(first.text, "="), first.span, "направление присваивания")
+		}
+	case "op()", "()op":
+		if p.noArgs(first, args) {
+			p.set(&p.unary, first.text, first.span, "синтаксис операций")
+		}
+	case "(op)":
+		if p.noArgs(first, args) {
+			p.set(&p.binary, first.text, first.span, "синтаксис бинарных операций")
+		}
+	case "unary", "binary":
+		p.syntaxDirective(first, args)
+	case "numeric":
+		if len(args) != 1 {
+			p.errorf(CodeSettingsValue, first.span, "numeric ожидает один аргумент: %s", strings.Join(numericBackends, ", "))
+		} else if _, err := NewNumericBackend(args[0].text); err != nil {
+			p.errorf(CodeSettingsValue, args[0].span, "%v", err)
+		} else {
+			p.dialect.Numeric = args[0].text
+		}
+	case "base-input", "base-output", "base-assign":
+		p.baseDirective(first, args)
+	case "precedence":
+		p.precedenceDirective(first, args)
+	case "assoc":
+		if len(args) != 2 {
+			p.errorf(CodeSettingsValue, first.span, "ожидалось: assoc <операция> left|right")
+		} else if op := p.binaryOp(args[0]); op != "" {
+			p.associativity(op, args[1])
+		}
+	default:
+		if len(args) == 1 && isCommandName(first.text) {
+			p.synonym(first, args[0])
+		} else if len(args) == 1 {
+			p.errorf(CodeSettingsUnknown, first.span, "неизвестная операция %q", first.text)
+		} else {
+			p.errorf(CodeSettingsUnknown, first.span, "неизвестная директива %q", first.text)
+		}
+	}
+}
+
+func (p *settingsParser) noArgs(directive settingsField, args []settingsField) bool {
+	if len(args) != 0 {
+		p.errorf(CodeSettingsValue, args[0].span, "%s не принимает аргументов", directive.text)
+		return false
+	}
+	return true
+}
+
+// повторная директива допустима только с тем же значением
+func (p *settingsParser) set(target **setting, value string, span Span, what string) {
+	if *target != nil && (*target).value != value {
+		p.errorf(CodeSettingsConflict, span, "%s: значение %s уже задано%s", what, (*target).value, lineRef((*target).span))
+		return
+	}
+	*target = &setting{value, span}
+}
+
+func (p *settingsParser) versionDirective(directive settingsField, args []settingsField) {
+	if p.seen || p.version != nil {
+		p.errorf(CodeSettingsVersion, directive.span, "version должна быть первой директивой")
+		return
+	}
+	if len(args) != 1 {
+		p.errorf(CodeSettingsVersion, directive.span, "version ожидает номер версии")
+		return
+	}
+	version, err := strconv.Atoi(args[0].text)
+	if err != nil || version < 1 || version > settingsVersion {
+		p.errorf(CodeSettingsVersion, args[0].span, "неподдерживаемая версия %s, поддерживается до %d", args[0].text, settingsVersion)
+		return
+	}
+	p.version = &setting{args[0].text, args[0].span}
+	p.dialect.Version = version
+}
+
+// unary op()|()op, binary op()|()op|(op)
+func (p *settingsParser) syntaxDirective(directive settingsField, args []settingsField) {
+	if len(args) != 1 {
+		p.errorf(CodeSettingsValue, directive.span, "%s ожидает один аргумент", directive.text)
+		return
+	}
+	value := args[0]
+	switch {
+	case directive.text == "unary" && (value.text == "op()" || value.text == "()op"):
+		p.set(&p.unary, value.text, value.span, "синтаксис унарных операций")
+	case directive.text == "binary" && (value.text == "op()" || value.text == "()op" || value.text == "(op)"):
+		p.set(&p.binary, value.text, value.span, "синтаксис бинарных операций")
+	default:
+		p.errorf(CodeSettingsValue, value.span, "недопустимый синтаксис %s для %s", value.text, directive.text)
+	}
+}
+
+// precedence <операция> <уровень> [left|right]
+func (p *settingsParser) precedenceDirective(directive settingsField, args []settingsField) {
+	if len(args) != 2 && len(args) != 3 {
+		p.errorf(CodeSettingsValue, directive.span, "ожидалось: precedence <операция> <уровень> [left|right]")
+		return
+	}
+	op := p.binaryOp(args[0])
+	if op == "" {
+		return
+	}
+	level, err := strconv.Atoi(args[1].text)
+	if err != nil || level < 1 {
+		p.errorf(CodeSettingsValue, args[1].span, "некорректный уровень %s", args[1].text)
+		return
+	}
+	p.dialect.Precedence[op] = level
+	if len(args) == 3 {
+		p.associativity(op, args[2])
+	}
+}
+
+// исходное имя бинарной операции по имени или синониму, "" при ошибке
+func (p *settingsParser) binaryOp(field settingsField) string {
+	op := field.text
+	if owner, ok := p.owners[op]; ok {
+		op = owner
+	}
+	if _, ok := defaultPrecedence[op]; !ok {
+		p.errorf(CodeSettingsValue, field.span, "%s не бинарная операция", field.text)
+		return ""
+	}
+	return op
+}
+
+func (p *settingsParser) associativity(op string, value settingsField) {
+	switch value.text {
+	case "left":
+		p.dialect.RightAssoc[op] = false
+	case "right":
+		p.dialect.RightAssoc[op] = true
+	default:
+		p.errorf(CodeSettingsValue, value.span, "ожидалось left или right, найдено %s", value.text)
+	}
+}
+
+// base-input|base-output|base-assign <основание>
+func (p *settingsParser) baseDirective(directive settingsField, args []settingsField) {
+	if len(args) != 1 {
+		p.errorf(CodeSettingsValue, directive.span, "%s ожидает основание системы счисления", directive.text)
+		return
+	}
+	base, err := strconv.Atoi(args[0].text)
+	if err == nil {
+		err = CheckBase(directive.text, base)
+	}
+	if err != nil {
+		p.errorf(CodeSettingsValue, args[0].span, "некорректное основание %s для %s", args[0].text, directive.text)
+		return
+	}
+	switch directive.text {
+	case "base-input":
+		p.dialect.BaseInput = base
+	case "base-output":
+		p.dialect.BaseOutput = base
+	case "base-assign":
+		p.dialect.BaseAssign = base
+	}
+}
+
+func (p *settingsParser) synonym(command, synonym settingsField) {
+	op, name := command.text, synonym.text
+	if problem := synonymProblem(name); problem != "" {
+		p.errorf(CodeSettingsSynonym, synonym.span, "%s", problem)
+		return
+	}
+	if prev, ok := p.synonyms[op]; ok {
+		p.errorf(CodeSettingsCollision, command.span, "синоним для %s уже задан%s", op, lineRef(prev))
+		return
+	}
+	if owner, ok := p.owners[name]; ok {
+		p.errorf(CodeSettingsCollision, synonym.span, "синоним %q уже занят операцией %s%s", name, owner, lineRef(p.synonyms[owner]))
+		return
+	}
+	if name != op && isCommandName(name) {
+		p.errorf(CodeSettingsCollision, synonym.span, "синоним %q совпадает с именем операции %s", name, name)
+		return
+	}
+	p.synonyms[op] = synonym.span
+	p.owners[name] = op
+	p.dialect.Commands[op] = name
+}
+
+// " в строке N", если строка известна
+func lineRef(span Span) string {
+	if span.Start.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" в строке %d", span.Start.Line)
+}
+
+// причина, по которой строку нельзя использовать как синоним, или ""
+func synonymProblem(name string) string {
+	if name == "" {
+		return "синоним не может быть пустым"
+	}
+	for _, r := range name {
+		if unicode.IsSpace(r) || strings.ContainsRune(forbiddenSynonymChars, r) {
+			return fmt.Sprintf("синоним %q содержит недопустимый символ %q", name, r)
+		}
+	}
+	if r := []rune(name)[0]; unicode.IsDigit(r) {
+		return fmt.Sprintf("синоним %q не может начинаться с цифры", name)
+	}
+	return ""
+}
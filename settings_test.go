@@ -0,0 +1,89 @@
+package interp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEmptySynonym(t *testing.T) {
+	_, jsonErr := ParseJSONSettings("settings.json", []byte(`{"commands":{"add":""}}`))
+	_, tomlErr := ParseTOMLSettings("settings.toml", "[commands]\nadd = \"\"\n")
+	for _, err := range []error{jsonErr, tomlErr} {
+		if err == nil || !strings.Contains(err.Error(), CodeSettingsSynonym) {
+			t.Errorf("ожидалась ошибка %s, получено %v", CodeSettingsSynonym, err)
+		}
+	}
+}
+
+// одна ошибочная строка на каждый код: код, место и подчёркивание
+func TestSettingsErrors(t *testing.T) {
+	tests := []struct {
+		settings string
+		code     string
+		pos      string
+		caret    string
+	}{
+		{"left=\nфрукт яблоко груша\n", CodeSettingsUnknown, "2:1", "   | ^~~~~\n"},
+		{"left=\nadd su(m\n", CodeSettingsSynonym, "2:5", "   |     ^~~~\n"},
+		{"add x\nsub x\n", CodeSettingsCollision, "2:5", "   |     ^\n"},
+		{"left=\nright=\n", CodeSettingsConflict, "2:1", "   | ^~~~~~\n"},
+		{"left=\nbase-assign 99\n", CodeSettingsValue, "2:13", "   |             ^~\n"},
+		{"left=\nadd plus ]\n", CodeSettingsComment, "2:10", "   |          ^\n"},
+		{"left=\nversion 1\n", CodeSettingsVersion, "2:1", "   | ^~~~~~~\n"},
+	}
+	for _, test := range tests {
+		_, err := ParseSettings("settings.txt", test.settings)
+		diags, ok := err.(Diagnostics)
+		if !ok || len(diags) != 1 {
+			t.Errorf("%q: ожидалась одна диагностика, получено %v", test.settings, err)
+			continue
+		}
+		d := diags[0]
+		if pos := fmt.Sprintf("%d:%d", d.Span.Start.Line, d.Span.Start.Col); d.Code != test.code || pos != test.pos {
+			t.Errorf("%q: %s в %s, ожидалось %s в %s", test.settings, d.Code, pos, test.code, test.pos)
+		}
+		if rendered := d.Render(test.settings); !strings.HasSuffix(rendered, test.caret) {
+			t.Errorf("%q: подчёркивание не на месте:\n%s", test.settings, rendered)
+		}
+	}
+}
+
+// примеры настроек из репозитория разбираются без ошибок
+func TestSampleSettings(t *testing.T) {
+	for _, file := range []string{"settings.txt", "bigs_settings.txt"} {
+		if _, err := LoadDialect(file); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+// ошибки JSON указывают на пару "ключ": значение, как в txt и TOML
+func TestJSONSettingsErrors(t *testing.T) {
+	tests := []struct {
+		settings string
+		code     string
+		pos      string
+		caret    string
+	}{
+		{"{\n  \"commands\": {\n    \"add\": \"su(m\"\n  }\n}\n", CodeSettingsSynonym, "3:5", "   |     ^~~~~~~~~~~~~\n"},
+		{"{\n  \"commands\": {\"add\": [1]}\n}\n", CodeSettingsValue, "2:16", "   |                ^~~~~~~~~~\n"},
+		{"{\n  \"bases\": 5\n}\n", CodeSettingsValue, "2:3", "   |   ^~~~~~~~~~\n"},
+		{"{\"numeric\": \"32\",\n \"фрукт\": \"яблоко\"}\n", CodeSettingsUnknown, "2:2", "   |  ^~~~~~~~~~~~~~~~~\n"},
+	}
+	for _, test := range tests {
+		_, err := ParseJSONSettings("settings.json", []byte(test.settings))
+		diags, ok := err.(Diagnostics)
+		if !ok || len(diags) != 1 {
+			t.Errorf("%q: ожидалась одна диагностика, получено %v", test.settings, err)
+			continue
+		}
+		d := diags[0]
+		if pos := fmt.Sprintf("%d:%d", d.Span.Start.Line, d.Span.Start.Col); d.Code != test.code || pos != test.pos {
+			t.Errorf("%q: %s в %s, ожидалось %s в %s", test.settings, d.Code, pos, test.code, test.pos)
+		}
+		if rendered := d.Render(test.settings); !strings.HasSuffix(rendered, test.caret) {
+			t.Errorf("%q: подчёркивание не на месте:\n%s", test.settings, rendered)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Перевод программы из диалекта from в диалект to: разбор в исходном диалекте
+// и печать дерева синонимами и синтаксисом целевого; литералы переводятся
+// из основания присваивания исходного диалекта в основание целевого
+func Translate(from, to *Interpreter, file, program string) (string, error) {
+	prog, err := from.Parse(file, program)
+	if err != nil {
+		return "", err
+	}
+	if err := checkTargetNames(prog, to); err != nil {
+		return "", err
+	}
+
+	printer := NewPrinter(to)
+	printer.Literal = func(text string) (string, error) {
+		value, err := from.Numeric.Parse(text, from.BaseAssign)
+		if err != nil {
+			return text, err
+		}
+		// литерал шире целевого режима взялся бы по модулю и изменил программу
+		decimal := from.Numeric.Format(value, 10)
+		converted, err := to.Numeric.Parse(decimal, 10)
+		if err != nil || to.Numeric.Format(converted, 10) != decimal {
+			return text, fmt.Errorf("число %s не помещается в режим %s", text, to.Numeric.Name())
+		}
+		translated := to.Numeric.Format(converted, to.BaseAssign)
+		// литерал должен начинаться с цифры, иначе это имя переменной
+		if translated != "" && (translated[0] < '0' || translated[0] > '9') {
+			translated = "0" + translated
+		}
+		return translated, nil
+	}
+	text, err := printer.Program(prog)
+	if err != nil {
+		return "", err
+	}
+	if _, err := to.Parse(file, text); err != nil {
+		return "", fmt.Errorf("перевод не разбирается в целевом диалекте: %v", err)
+	}
+	return text, nil
+}
+
+// имена переменных и функций, совпадающие со словами-синонимами целевого диалекта, не переводятся
+func checkTargetNames(prog *Program, to *Interpreter) error {
+	words := make(map[string]string)
+	for original, synonym := range to.Commands {
+		if isWord(synonym) {
+			words[synonym] = original
+		}
+	}
+	var err error
+	check := func(name *Ident, kind string) {
+		if original, ok := words[name.Text]; ok && err == nil {
+			err = fmt.Errorf("%s: %s %s совпадает с синонимом %s в целевом диалекте", name.Span, kind, name.Text, original)
+		}
+	}
+	walkStmts(prog.Stmts, func(stmt Stmt) {
+		switch s := stmt.(type) {
+		case *AssignStmt:
+			check(s.Name, "переменная")
+		case *FuncDecl:
+			check(s.Name, "функция")
+			for _, param := range s.Params {
+				check(param, "параметр")
+			}
+		}
+	})
+	return err
+}
+
+// результат выполнения для сравнения переводов
+type runResult struct {
+	outputs   []string // выведенные значения в десятичной записи
+	variables map[string]string
+	err       string
+}
+
+// инструкций в одном выполнении при проверке: ввод бесконечен, и цикл с input мог бы не закончиться
+const translationSteps = 1000000
+
+// бесконечный ввод: строка line повторяется
+type endlessInput struct {
+	line string
+	pos  int
+}
+
+func (r *endlessInput) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.line[r.pos]
+		r.pos = (r.pos + 1) % len(r.line)
+	}
+	return len(p), nil
+}
+
+// выполнение на копии интерпретатора с вводом 1 для каждого input и перехватом вывода;
+// переменные, функции, ввод-вывод и режим отладки interp не меняются
+func translationRun(interp *Interpreter, program string) runResult {
+	var out bytes.Buffer
+	run := *interp
+	run.Variables, run.VarHistory, run.Functions = NewTrie(), NewTrie(), make(map[string]*FuncDecl)
+	run.In, run.Out, run.Prompt = &endlessInput{line: "1\n"}, &out, nil
+	run.Debug, run.Debugger, run.Breakpoints, run.Watches, run.frames = false, nil, nil, nil, nil
+	if run.Limits.MaxSteps == 0 {
+		run.Limits.MaxSteps = translationSteps
+	}
+	var result runResult
+	if err := run.Execute(program); err != nil {
+		var codes []string
+		if diags, ok := err.(Diagnostics); ok {
+			for _, d := range diags {
+				codes = append(codes, fmt.Sprintf("%s в инструкции %d", d.Code, d.Stmt))
+			}
+		}
+		result.err = strings.Join(codes, ", ")
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		i := strings.LastIndex(line, " = ")
+		if i < 0 {
+			continue
+		}
+		text := line[i+3:]
+		if value, err := run.Numeric.Parse(text, run.BaseOutput); err == nil {
+			text = run.DecimalToBase(value, 10)
+		}
+		result.outputs = append(result.outputs, text)
+	}
+	result.variables = make(map[string]string)
+	for _, name := range run.Variables.KeysWithPrefix("") {
+		result.variables[name] = run.DecimalToBase(run.Variables.Search(name), 10)
+	}
+	return result
+}
+
+// Проверка перевода: обе версии выполняются с одинаковым вводом,
+// выводы, значения переменных и ошибки должны совпасть
+func VerifyTranslation(from, to *Interpreter, program, translated string) error {
+	source := translationRun(from, program)
+	target := translationRun(to, translated)
+
+	if source.err != target.err {
+		return fmt.Errorf("ошибки выполнения различаются: %q и %q", source.err, target.err)
+	}
+	if strings.Join(source.outputs, ",") != strings.Join(target.outputs, ",") {
+		return fmt.Errorf("вывод различается: %s и %s", strings.Join(source.outputs, ", "), strings.Join(target.outputs, ", "))
+	}
+	names := make([]string, 0, len(source.variables))
+	for name := range source.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if target.variables[name] != source.variables[name] {
+			return fmt.Errorf("значение %s различается: %s и %s", name, source.variables[name], target.variables[name])
+		}
+	}
+	if len(target.variables) != len(source.variables) {
+		return fmt.Errorf("наборы переменных различаются")
+	}
+	return nil
+}
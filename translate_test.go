@@ -0,0 +1,118 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var translationDialects = []struct {
+	name     string
+	settings string
+}{
+	{"по умолчанию", ""},
+	{"()op и (op)", "left=\n()op\n(op)\nadd sum\nsub minus\ninput in\noutput print\n= ->\n"},
+	{"справа, op() и ()op, основание 16", "right=\nunary op()\nbinary ()op\nadd plus\n= <-\nbase-assign 16\n"},
+	{"(op), основание 2", "left=\nunary ()op\nbinary (op)\nmult times\nwhile пока\nbase-assign 2\n"},
+}
+
+func translationInterpreter(t *testing.T, settings string) *Interpreter {
+	t.Helper()
+	d, err := ParseSettings("settings.txt", settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := New(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return interp
+}
+
+// перевод между каждой парой диалектов проходит проверку выполнением,
+// а обратный перевод совпадает с исходной программой
+func TestTranslateRoundTrip(t *testing.T) {
+	program := `n = 12;
+s = 0;
+while n {
+	x = input();
+	s = add(s, mult(x, 255));
+	n = sub(n, 1);
+}
+output(s);
+output(not(s));
+if sub(s, 3060) {
+	output(0);
+} else {
+	output(xor(s, 4096));
+}
+`
+	for _, from := range translationDialects {
+		for _, to := range translationDialects {
+			source := translationInterpreter(t, "")
+			target := translationInterpreter(t, to.settings)
+			origin := translationInterpreter(t, from.settings)
+			// программа в исходном диалекте
+			text, err := Translate(source, origin, "test", program)
+			if err != nil {
+				t.Fatalf("%s: %v", from.name, err)
+			}
+			if result := translationRun(origin, text); result.err != "" || len(result.outputs) != 3 {
+				t.Fatalf("%s: ошибка %q, вывод %v", from.name, result.err, result.outputs)
+			}
+			translated, err := Translate(origin, target, "test", text)
+			if err != nil {
+				t.Errorf("%s -> %s: %v", from.name, to.name, err)
+				continue
+			}
+			if err := VerifyTranslation(origin, target, text, translated); err != nil {
+				t.Errorf("%s -> %s: %v\n%s", from.name, to.name, err, translated)
+			}
+			back, err := Translate(target, origin, "test", translated)
+			if err != nil || back != text {
+				t.Errorf("%s -> %s -> %s: %v\n%s\nожидалось\n%s", from.name, to.name, from.name, err, back, text)
+			}
+		}
+	}
+}
+
+// ввода хватает на input в цикле, бесконечный цикл с input ограничен числом инструкций
+func TestTranslationRunInput(t *testing.T) {
+	interp := translationInterpreter(t, "")
+	loop := translationRun(interp, "n = 20;\nwhile n {\n\tx = input();\n\tn = sub(n, x);\n}\noutput(n);\n")
+	if loop.err != "" || strings.Join(loop.outputs, ",") != "0" {
+		t.Errorf("цикл с input: ошибка %q, вывод %v", loop.err, loop.outputs)
+	}
+	endless := translationRun(interp, "x = 1;\nwhile x {\n\tx = input();\n}\n")
+	if !strings.Contains(endless.err, CodeStepLimit) {
+		t.Errorf("бесконечный цикл: ошибка %q, ожидалось %s", endless.err, CodeStepLimit)
+	}
+}
+
+// проверка перевода не меняет интерпретаторы вызывающего
+func TestVerifyTranslationKeepsInterpreter(t *testing.T) {
+	interp := translationInterpreter(t, "")
+	var out bytes.Buffer
+	in := strings.NewReader("7\n")
+	interp.In, interp.Out, interp.Debug = in, &out, true
+	interp.Variables.Insert("y", interp.Numeric.FromInt64(5))
+	program := "func f(a) {\n\treturn add(a, 1);\n}\nv = input();\nx = f(v);\noutput(x);\n"
+	if result := translationRun(interp, program); result.err != "" || strings.Join(result.outputs, ",") != "2" {
+		t.Fatalf("ошибка %q, вывод %v", result.err, result.outputs)
+	}
+	if err := VerifyTranslation(interp, interp, program, program); err != nil {
+		t.Fatal(err)
+	}
+	if interp.In != in || interp.Out != &out || !interp.Debug || out.Len() != 0 {
+		t.Error("ввод, вывод или режим отладки изменились")
+	}
+	if names := interp.Variables.KeysWithPrefix(""); len(names) != 1 || names[0] != "y" {
+		t.Errorf("переменные изменились: %v", names)
+	}
+	if len(interp.Functions) != 0 {
+		t.Errorf("объявлены функции: %v", interp.Functions)
+	}
+	if err := VerifyTranslation(interp, interp, "output(1);\n", "output(2);\n"); err == nil {
+		t.Error("разный вывод не обнаружен")
+	}
+}
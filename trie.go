@@ -1,10 +1,14 @@
-package main
+package interp
 
+import (
+	"sort"
+	"unicode/utf8"
+)
 
 // Узел дерева
 type Node struct {
 	children    map[rune]*Node
-	value       interface{}
+	value       Value
 	isEndOfWord bool
 }
 
@@ -17,6 +21,7 @@ func NewNode() *Node {
 // Дерево
 type Trie struct {
 	root *Node
+	size int
 }
 
 //cоздание
@@ -27,7 +32,7 @@ func NewTrie() *Trie {
 }
 
 // Вставка
-func (t *Trie) Insert(key string, value interface{}) {
+func (t *Trie) Insert(key string, value Value) {
 	node := t.root
 	for _, char := range key {
 		if _, found := node.children[char]; !found {
@@ -35,12 +40,20 @@ func (t *Trie) Insert(key string, value interface{}) {
 		}
 		node = node.children[char]
 	}
+	if !node.isEndOfWord {
+		t.size++
+	}
 	node.isEndOfWord = true
 	node.value = value
 }
 
+// Число ключей
+func (t *Trie) Len() int {
+	return t.size
+}
+
 // Поиск
-func (t *Trie) Search(key string) interface{} {
+func (t *Trie) Search(key string) Value {
 	node := t.root
 	for _, char := range key {
 		if _, found := node.children[char]; !found {
@@ -54,31 +67,27 @@ func (t *Trie) Search(key string) interface{} {
 	return nil
 }
 
-// Удаление
-func (t *Trie) Delete(key string) {
-	var delet func(node *Node, key string, depth int) bool
-	delet = func(node *Node, key string, depth int) bool {
-		if node == nil {
+// Удаление; false - ключа не было
+func (t *Trie) Delete(key string) bool {
+	path := []*Node{t.root}
+	node := t.root
+	for _, char := range key {
+		if node = node.children[char]; node == nil {
 			return false
 		}
-
-		if depth == len(key) {
-			if node.isEndOfWord {
-				node.isEndOfWord = false
-			}
-			return len(node.children) == 0
-		}
-
-		char := rune(key[depth])
-		if delet(node.children[char], key, depth+1) {
-			delete(node.children, char)
-			return len(node.children) == 0 && !node.isEndOfWord
-		}
-
+		path = append(path, node)
+	}
+	if !node.isEndOfWord {
 		return false
 	}
-
-	delet(t.root, key, 0)
+	node.isEndOfWord, node.value = false, nil
+	t.size--
+	// узлы, за которыми не осталось ключей, убираются от конца ключа к корню
+	chars := []rune(key)
+	for i := len(chars); i > 0 && len(path[i].children) == 0 && !path[i].isEndOfWord; i-- {
+		delete(path[i-1].children, chars[i-1])
+	}
+	return true
 }
 
 // Получение всех ключей
@@ -100,3 +109,41 @@ func (t *Trie) ObtainAll() []string {
 	obtainAll(t.root, "")
 	return results
 }
+
+// Все ключи с заданным префиксом в порядке возрастания
+func (t *Trie) KeysWithPrefix(prefix string) []string {
+	node := t.root
+	for _, char := range prefix {
+		if node = node.children[char]; node == nil {
+			return nil
+		}
+	}
+	var results []string
+	var collect func(node *Node, key string)
+	collect = func(node *Node, key string) {
+		if node.isEndOfWord {
+			results = append(results, key)
+		}
+		for char, nextNode := range node.children {
+			collect(nextNode, key+string(char))
+		}
+	}
+	collect(node, prefix)
+	sort.Strings(results)
+	return results
+}
+
+// Самый длинный ключ, являющийся префиксом s; "" если такого нет
+func (t *Trie) LongestPrefixOf(s string) string {
+	node := t.root
+	longest := 0
+	for i, char := range s {
+		if node = node.children[char]; node == nil {
+			break
+		}
+		if node.isEndOfWord {
+			longest = i + utf8.RuneLen(char)
+		}
+	}
+	return s[:longest]
+}
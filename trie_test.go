@@ -0,0 +1,35 @@
+package interp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrieDelete(t *testing.T) {
+	trie := NewTrie()
+	for _, key := range []string{"переменная", "пере", "x", "xy"} {
+		trie.Insert(key, uint64(len(key)))
+	}
+	if !trie.Delete("переменная") {
+		t.Fatal("переменная не удалена")
+	}
+	if trie.Delete("переменная") || trie.Delete("пер") || trie.Delete("z") {
+		t.Error("удалён отсутствующий ключ")
+	}
+	if !trie.Delete("x") {
+		t.Fatal("x не удалён")
+	}
+	if got := trie.Search("переменная"); got != nil {
+		t.Errorf("после удаления найдено %v", got)
+	}
+	if got, want := trie.KeysWithPrefix(""), []string{"xy", "пере"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ключи %v, ожидалось %v", got, want)
+	}
+	if trie.Len() != 2 {
+		t.Errorf("Len = %d, ожидалось 2", trie.Len())
+	}
+	trie.Delete("пере")
+	if len(trie.root.children) != 1 {
+		t.Errorf("после удаления остались пустые узлы: %d ветвей от корня", len(trie.root.children))
+	}
+}
@@ -0,0 +1,508 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Байт-код программы: дерево компилируется один раз, имена переменных заменяются номерами ячеек,
+// литералы разбираются заранее, а выполнение идёт на стековой машине с операндами uint64.
+// Используется в режимах 32 и 64 вне отладчика; в режиме big и под отладчиком, которому нужны
+// остановки на инструкциях, программа выполняется обходом дерева.
+type Bytecode struct {
+	code     []instr
+	consts   []uint64
+	globals  []string // имена глобальных переменных по номерам ячеек
+	funcs    []compiledFunc
+	stmts    []stmtCode
+	failures []*Diagnostic // ошибки, найденные при компиляции, выдаются при выполнении
+	nodes    []AstNode     // узлы для сообщений об ошибках; nodes[0] - нет узла
+}
+
+type opcode uint8
+
+const (
+	opHalt       opcode = iota
+	opConst             // константа consts[arg]
+	opLoad              // глобальная переменная arg
+	opLoadLocal         // локальная переменная arg, а пока она не задана - глобальная arg2
+	opStore             // снятие вершины стека в глобальную переменную arg
+	opStoreLocal        // снятие вершины стека в локальную переменную arg
+	opPop
+	opInput  // ввод значения переменной присваивания nodes[node]
+	opOutput // вывод вершины стека без снятия
+	opNot
+	opAdd
+	opSub
+	opMult
+	opDiv
+	opRem
+	opPow
+	opXor
+	opAnd
+	opOr
+	opJump       // переход на arg
+	opJumpIfZero // снятие условия и переход на arg, если оно ноль
+	opCall       // вызов функции funcs[arg]
+	opReturn
+	opFail // ошибка failures[arg]
+	opStep // начало инструкции программы: учёт для ограничения числа инструкций
+)
+
+var binaryOpcodes = map[string]opcode{
+	"add": opAdd, "sub": opSub, "mult": opMult, "div": opDiv, "rem": opRem,
+	"pow": opPow, "xor": opXor, "and": opAnd, "or": opOr,
+}
+
+type instr struct {
+	op   opcode
+	arg  int32
+	arg2 int32
+	stmt int32 // инструкция программы в Bytecode.stmts, к которой относится команда
+	node int32 // узел в Bytecode.nodes; в команде нет указателей, и рост кода не нагружает сборщик мусора
+}
+
+type compiledFunc struct {
+	decl   *FuncDecl
+	entry  int
+	locals int
+}
+
+// инструкция программы и адрес, с которого выполнение продолжается после её ошибки
+type stmtCode struct {
+	stmt Stmt
+	end  int
+}
+
+var errNotCompilable = errors.New("байт-код поддерживает только режимы 32 и 64")
+
+// Компиляция программы в байт-код. Функции берутся из Functions: их нужно объявить заранее
+func (interp *Interpreter) Compile(prog *Program) (*Bytecode, error) {
+	if _, ok := interp.Numeric.(fixedBackend); !ok {
+		return nil, errNotCompilable
+	}
+	// команд примерно столько же, сколько узлов: память под код выделяется сразу
+	size := 1
+	walkStmts(prog.Stmts, func(stmt Stmt) {
+		size += 2
+		if expr := stmtExpr(stmt); expr != nil {
+			walkExpr(expr, func(Expr) { size++ })
+		}
+	})
+	c := &compiler{interp: interp, bc: &Bytecode{code: make([]instr, 0, size), nodes: []AstNode{nil}}, globals: make(map[string]int), funcs: make(map[string]int), consts: make(map[uint64]int), literals: make(map[string]int)}
+	c.block(prog.Stmts)
+	c.emit(opHalt, 0, 0, nil)
+	// тела функций компилируются по мере появления вызовов, в том числе из других функций
+	for i := 0; i < len(c.bc.funcs); i++ {
+		c.function(&c.bc.funcs[i])
+	}
+	return c.bc, nil
+}
+
+type compiler struct {
+	interp  *Interpreter
+	bc      *Bytecode
+	globals map[string]int
+	funcs   map[string]int
+	consts  map[uint64]int
+	// литералы, уже разобранные в BaseAssign
+	literals map[string]int
+	locals   map[string]int // nil вне функции
+	stmt     int
+}
+
+func (c *compiler) emit(op opcode, arg, arg2 int, node AstNode) int {
+	index := 0
+	if node != nil {
+		c.bc.nodes = append(c.bc.nodes, node)
+		index = len(c.bc.nodes) - 1
+	}
+	c.bc.code = append(c.bc.code, instr{op: op, arg: int32(arg), arg2: int32(arg2), stmt: int32(c.stmt), node: int32(index)})
+	return len(c.bc.code) - 1
+}
+
+// адрес перехода jump - следующая команда
+func (c *compiler) patch(jump int) {
+	c.bc.code[jump].arg = int32(len(c.bc.code))
+}
+
+func (c *compiler) fail(err error) {
+	c.bc.failures = append(c.bc.failures, err.(*Diagnostic))
+	c.emit(opFail, len(c.bc.failures)-1, 0, nil)
+}
+
+func (c *compiler) constant(value uint64) int {
+	if index, ok := c.consts[value]; ok {
+		return index
+	}
+	c.bc.consts = append(c.bc.consts, value)
+	c.consts[value] = len(c.bc.consts) - 1
+	return len(c.bc.consts) - 1
+}
+
+func (c *compiler) global(name string) int {
+	if slot, ok := c.globals[name]; ok {
+		return slot
+	}
+	c.bc.globals = append(c.bc.globals, name)
+	c.globals[name] = len(c.bc.globals) - 1
+	return len(c.bc.globals) - 1
+}
+
+// номер функции; false - функция не объявлена
+func (c *compiler) funcIndex(name string) (int, bool) {
+	if index, ok := c.funcs[name]; ok {
+		return index, true
+	}
+	decl := c.interp.Functions[name]
+	if decl == nil {
+		return 0, false
+	}
+	c.bc.funcs = append(c.bc.funcs, compiledFunc{decl: decl})
+	c.funcs[name] = len(c.bc.funcs) - 1
+	return len(c.bc.funcs) - 1, true
+}
+
+// тело функции: параметры и переменные, которым в ней присваивается значение, - локальные
+func (c *compiler) function(f *compiledFunc) {
+	c.locals = make(map[string]int)
+	for _, param := range f.decl.Params {
+		c.locals[param.Text] = len(c.locals)
+	}
+	walkStmts(f.decl.Body.Stmts, func(stmt Stmt) {
+		if assign, ok := stmt.(*AssignStmt); ok {
+			if _, ok := c.locals[assign.Name.Text]; !ok {
+				c.locals[assign.Name.Text] = len(c.locals)
+			}
+		}
+	})
+	f.entry, f.locals = len(c.bc.code), len(c.locals)
+	c.block(f.decl.Body.Stmts)
+	// без return функция возвращает 0
+	c.emit(opConst, c.constant(0), 0, nil)
+	c.emit(opReturn, 0, 0, nil)
+	c.locals = nil
+}
+
+func (c *compiler) block(stmts []Stmt) {
+	for _, stmt := range stmts {
+		c.statement(stmt)
+	}
+}
+
+func (c *compiler) statement(stmt Stmt) {
+	saved := c.stmt
+	c.stmt = len(c.bc.stmts)
+	c.bc.stmts = append(c.bc.stmts, stmtCode{stmt: stmt})
+	// у цикла учитывается каждая проверка условия
+	if _, ok := stmt.(*WhileStmt); !ok {
+		c.emit(opStep, 0, 0, nil)
+	}
+	switch s := stmt.(type) {
+	case *AssignStmt:
+		if call, ok := s.Value.(*CallExpr); ok && call.Op.Op == "input" {
+			c.emit(opInput, 0, 0, s)
+		} else {
+			c.expr(s.Value)
+		}
+		if slot, ok := c.locals[s.Name.Text]; ok {
+			c.emit(opStoreLocal, slot, 0, nil)
+		} else {
+			c.emit(opStore, c.global(s.Name.Text), 0, nil)
+		}
+	case *ExprStmt:
+		c.expr(s.X)
+		c.emit(opPop, 0, 0, nil)
+	case *ReturnStmt:
+		c.expr(s.Value)
+		c.emit(opReturn, 0, 0, nil)
+	case *IfStmt:
+		c.expr(s.Cond)
+		jump := c.emit(opJumpIfZero, 0, 0, nil)
+		c.block(s.Then.Stmts)
+		if s.Else != nil {
+			skip := c.emit(opJump, 0, 0, nil)
+			c.patch(jump)
+			c.block(s.Else.Stmts)
+			jump = skip
+		}
+		c.patch(jump)
+	case *WhileStmt:
+		start := c.emit(opStep, 0, 0, nil)
+		c.expr(s.Cond)
+		exit := c.emit(opJumpIfZero, 0, 0, nil)
+		c.block(s.Body.Stmts)
+		c.emit(opJump, start, 0, nil)
+		c.patch(exit)
+	}
+	c.bc.stmts[c.stmt].end = len(c.bc.code)
+	c.stmt = saved
+}
+
+func (c *compiler) expr(expr Expr) {
+	switch e := expr.(type) {
+	case *NumberLit:
+		index, ok := c.literals[e.Text]
+		if !ok {
+			value, err := c.interp.literal(e)
+			if err != nil {
+				c.fail(err)
+				return
+			}
+			index = c.constant(value.(uint64))
+			c.literals[e.Text] = index
+		}
+		c.emit(opConst, index, 0, nil)
+	case *Ident:
+		if slot, ok := c.locals[e.Text]; ok {
+			c.emit(opLoadLocal, slot, c.global(e.Text), nil)
+		} else {
+			c.emit(opLoad, c.global(e.Text), 0, nil)
+		}
+	case *UnaryExpr:
+		c.expr(e.X)
+		c.emit(opNot, 0, 0, e)
+	case *BinaryExpr:
+		c.expr(e.X)
+		c.expr(e.Y)
+		c.emit(binaryOpcodes[e.Op.Op], 0, 0, e)
+	case *FuncCall:
+		index, ok := c.funcIndex(e.Name.Text)
+		if !ok {
+			c.fail(undefinedFunction(e))
+			return
+		}
+		for _, arg := range e.Args {
+			c.expr(arg)
+		}
+		c.emit(opCall, index, 0, e)
+	case *CallExpr:
+		if e.Op.Op != "output" {
+			c.fail(misplacedIO(e))
+			return
+		}
+		c.expr(e.Args[0])
+		c.emit(opOutput, 0, 0, e)
+	}
+}
+
+// ошибка чтения необъявленной переменной. Команды чтения не хранят узел: их в коде больше всего,
+// а переменная в выражении инструкции ищется по имени - до первого её вхождения вычисление не дойдёт
+func (bc *Bytecode) undefined(in *instr) error {
+	name := bc.globals[in.arg]
+	if in.op == opLoadLocal {
+		name = bc.globals[in.arg2]
+	}
+	var ident *Ident
+	walkExpr(stmtExpr(bc.stmts[in.stmt].stmt), func(e Expr) {
+		if id, ok := e.(*Ident); ok && id.Text == name && ident == nil {
+			ident = id
+		}
+	})
+	return undefinedVariable(ident)
+}
+
+// кадр вызова: адрес возврата, начало локальных переменных, глубина стека до аргументов
+// и число заданных локальных переменных
+type vmFrame struct {
+	ret     int
+	base    int
+	stack   int
+	defined int
+}
+
+// выполнение байт-кода; переменные программы читаются из Variables и записываются туда по окончании
+func (interp *Interpreter) runBytecode(bc *Bytecode, diags *Diagnostics) {
+	mask := interp.Numeric.(fixedBackend).mask
+	globals := make([]uint64, len(bc.globals))
+	set := make([]bool, len(bc.globals))
+	for i, name := range bc.globals {
+		if value, ok := interp.Variables.Search(name).(uint64); ok {
+			globals[i], set[i] = value, true
+		}
+	}
+	defer func() {
+		for i, name := range bc.globals {
+			if set[i] {
+				interp.Variables.Insert(name, globals[i])
+			}
+		}
+	}()
+
+	code, limit := bc.code, interp.maxCallDepth()
+	maxSteps, maxVars, defined := interp.Limits.MaxSteps, interp.Limits.MaxVariables, interp.Variables.Len()
+	stack := make([]uint64, 0, 64)
+	var (
+		locals   []uint64
+		localSet []bool
+		frames   []vmFrame
+		jumps    uint // переходы и вызовы с последней проверки отмены
+	)
+	for pc := 0; ; {
+		in := &code[pc]
+		pc++
+		var err error
+		switch in.op {
+		case opHalt:
+			return
+		case opConst:
+			stack = append(stack, bc.consts[in.arg])
+		case opLoad:
+			if !set[in.arg] {
+				err = bc.undefined(in)
+				break
+			}
+			stack = append(stack, globals[in.arg])
+		case opLoadLocal:
+			slot := frames[len(frames)-1].base + int(in.arg)
+			switch {
+			case localSet[slot]:
+				stack = append(stack, locals[slot])
+			case set[in.arg2]:
+				stack = append(stack, globals[in.arg2])
+			default:
+				err = bc.undefined(in)
+			}
+		case opStore:
+			if !set[in.arg] {
+				if maxVars > 0 && defined >= maxVars {
+					assign := bc.stmts[in.stmt].stmt.(*AssignStmt)
+					err = interp.exceed(variableLimit(assign.Name.Span, maxVars), assign)
+					break
+				}
+				defined++
+			}
+			globals[in.arg], set[in.arg] = stack[len(stack)-1], true
+			stack = stack[:len(stack)-1]
+		case opStoreLocal:
+			f := &frames[len(frames)-1]
+			slot := f.base + int(in.arg)
+			if !localSet[slot] {
+				if maxVars > 0 && f.defined >= maxVars {
+					assign := bc.stmts[in.stmt].stmt.(*AssignStmt)
+					err = interp.exceed(variableLimit(assign.Name.Span, maxVars), assign)
+					break
+				}
+				f.defined++
+			}
+			locals[slot], localSet[slot] = stack[len(stack)-1], true
+			stack = stack[:len(stack)-1]
+		case opPop:
+			stack = stack[:len(stack)-1]
+		case opInput:
+			assign := bc.nodes[in.node].(*AssignStmt)
+			interp.current = assign
+			var value Value
+			if value, err = interp.readInput(assign.Name.Text, assign.Value.(*CallExpr)); err == nil {
+				stack = append(stack, value.(uint64))
+			}
+		case opOutput:
+			interp.writeOutput(bc.nodes[in.node].(*CallExpr), stack[len(stack)-1])
+		case opNot:
+			stack[len(stack)-1] = ^stack[len(stack)-1] & mask
+		case opAdd, opSub, opMult, opDiv, opRem, opPow, opXor, opAnd, opOr:
+			x, y := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch in.op {
+			case opAdd:
+				x = (x + y) & mask
+			case opSub:
+				x = (x - y) & mask
+			case opMult:
+				x = (x * y) & mask
+			case opDiv, opRem:
+				if y == 0 {
+					err = divisionByZero(bc.nodes[in.node].(*BinaryExpr))
+				} else if in.op == opDiv {
+					x /= y
+				} else {
+					x %= y
+				}
+			case opPow:
+				result := uint64(1)
+				for ; y > 0; y >>= 1 {
+					if y&1 == 1 {
+						result *= x
+					}
+					x *= x
+				}
+				x = result & mask
+			case opXor:
+				x ^= y
+			case opAnd:
+				x &= y
+			case opOr:
+				x |= y
+			}
+			stack[len(stack)-1] = x
+		case opJump:
+			pc = int(in.arg)
+			// долго выполняются только циклы и рекурсия: отмена проверяется на переходах и вызовах
+			if jumps++; jumps&1023 == 0 && interp.interrupted(bc.stmts[in.stmt].stmt) {
+				return
+			}
+		case opJumpIfZero:
+			if stack[len(stack)-1] == 0 {
+				pc = int(in.arg)
+			}
+			stack = stack[:len(stack)-1]
+		case opCall:
+			if jumps++; jumps&1023 == 0 && interp.interrupted(bc.stmts[in.stmt].stmt) {
+				return
+			}
+			if len(frames) >= limit {
+				err = callDepthExceeded(bc.nodes[in.node].(*FuncCall), limit)
+				break
+			}
+			f := &bc.funcs[in.arg]
+			base, params := len(locals), len(f.decl.Params)
+			if maxVars > 0 && params > maxVars {
+				call := bc.nodes[in.node].(*FuncCall)
+				err = interp.exceed(variableLimit(call.Span, maxVars), bc.stmts[in.stmt].stmt)
+				break
+			}
+			for i := 0; i < f.locals; i++ {
+				locals, localSet = append(locals, 0), append(localSet, i < params)
+			}
+			copy(locals[base:], stack[len(stack)-params:])
+			stack = stack[:len(stack)-params]
+			frames = append(frames, vmFrame{ret: pc, base: base, stack: len(stack), defined: params})
+			pc = f.entry
+		case opReturn:
+			f := frames[len(frames)-1]
+			result := stack[len(stack)-1]
+			frames = frames[:len(frames)-1]
+			locals, localSet = locals[:f.base], localSet[:f.base]
+			stack = append(stack[:f.stack], result)
+			pc = f.ret
+		case opFail:
+			d := *bc.failures[in.arg]
+			err = &d
+		case opStep:
+			if interp.steps++; maxSteps > 0 && interp.steps > maxSteps {
+				stmt := bc.stmts[in.stmt].stmt
+				err = interp.exceed(stepLimit(stmt, maxSteps), stmt)
+			}
+		default:
+			panic(fmt.Sprintf("неизвестная команда байт-кода %d", in.op))
+		}
+		if err == nil {
+			continue
+		}
+		if err == errStopped {
+			return // превышено ограничение
+		}
+
+		*diags = append(*diags, stmtDiagnostic(err, bc.stmts[in.stmt].stmt))
+		if !interp.KeepGoing {
+			return
+		}
+		// ошибка в функции прерывает вызов целиком, выполнение продолжается после инструкции программы с вызовом
+		if len(frames) > 0 {
+			in = &code[frames[0].ret-1]
+			frames, locals, localSet = frames[:0], locals[:0], localSet[:0]
+		}
+		stack = stack[:0]
+		pc = bc.stmts[in.stmt].end
+	}
+}
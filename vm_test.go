@@ -0,0 +1,155 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// программы в диалекте по умолчанию и их ввод
+var equivalenceCases = []struct {
+	name    string
+	program string
+	input   string
+}{
+	{"арифметика", `
+a = input();
+b = mult(a, a);
+output(add(b, pow(2, 40)));
+output(xor(a, 255));
+output(not(a));
+output(sub(0, a));
+output(div(b, 7));
+output(rem(b, 7));
+output(or(and(a, 12), 3));
+`, "123456\n"},
+	{"цикл", `
+n = input();
+f = 1;
+while n {
+	f = mult(f, n);
+	n = sub(n, 1);
+}
+output(f);
+if sub(f, 120) {
+	output(0);
+} else {
+	output(1);
+}
+`, "5\n"},
+	{"функции", `
+func fib(n) {
+	if n {
+		if sub(n, 1) {
+			return add(fib(sub(n, 1)), fib(sub(n, 2)));
+		}
+		return 1;
+	}
+	return 0;
+}
+func sq(a, b) {
+	x = mult(a, b); # локальная x затеняет глобальную
+	return add(x, g);
+}
+g = 7;
+x = input();
+r = fib(x);
+output(r);
+t = sq(sq(2, 3), sub(x, 1));
+output(t);
+output(x);
+`, "12\n"},
+	{"ошибки", `
+a = 5;
+a = add(q, 1);
+output(a);
+b = div(a, 0);
+output(b);
+c = input();
+c = input(); # ввод закончился
+output(c);
+`, "7\n"},
+	{"мёртвые присваивания", `
+a = 1;
+a = 2;
+b = add(a, 3);
+b = input();
+output(b);
+i = 3;
+while i {
+	s = i;
+	s = mult(i, 2);
+	output(s);
+	i = sub(i, 1);
+}
+`, "9\n"},
+}
+
+// вывод программы вместе с ошибкой и значениями глобальных переменных
+func runCase(t *testing.T, numeric, program, input string, treeWalk, optimize, keepGoing bool) (output, vars string) {
+	t.Helper()
+	d := NewDialect()
+	d.Numeric = numeric
+	interp, err := New(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp.In, interp.Out = strings.NewReader(input), &out
+	interp.TreeWalk, interp.Optimize, interp.KeepGoing = treeWalk, optimize, keepGoing
+	if err := interp.Execute(program); err != nil {
+		out.WriteString("ошибка: " + err.Error())
+	}
+	var values []string
+	for _, name := range interp.Variables.KeysWithPrefix("") {
+		values = append(values, name+"="+interp.DecimalToBase(interp.Variables.Search(name), 10))
+	}
+	return out.String(), strings.Join(values, " ")
+}
+
+func TestTreeWalkAndBytecodeAgree(t *testing.T) {
+	for _, numeric := range numericBackends {
+		for _, test := range equivalenceCases {
+			for _, keepGoing := range []bool{false, true} {
+				treeOut, treeVars := runCase(t, numeric, test.program, test.input, true, false, keepGoing)
+				vmOut, vmVars := runCase(t, numeric, test.program, test.input, false, false, keepGoing)
+				if treeOut != vmOut || treeVars != vmVars {
+					t.Errorf("%s, режим %s, keep-going %v:\nобход дерева:\n%s\n%s\nбайт-код:\n%s\n%s",
+						test.name, numeric, keepGoing, treeOut, treeVars, vmOut, vmVars)
+				}
+			}
+		}
+	}
+}
+
+func benchmarkExecution(b *testing.B, treeWalk bool) {
+	interp, err := New(NewDialect())
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, test := range benchCases {
+		program, err := NewPrinter(interp).Program(&Program{Stmts: test.build(benchGen{interp}, 1000)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		prog, err := interp.Parse(test.name, program)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(test.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, _, err := benchRun(interp, prog, treeWalk); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkTreeWalk(b *testing.B) {
+	benchmarkExecution(b, true)
+}
+
+func BenchmarkVM(b *testing.B) {
+	benchmarkExecution(b, false)
+}
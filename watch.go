@@ -0,0 +1,154 @@
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Наблюдаемое выражение: пересчитывается после каждой инструкции,
+// при изменении значения выполнение останавливается
+type Watch struct {
+	Expr     string
+	Value    string // текущее значение в десятичной записи или текст ошибки
+	Previous string
+	Changed  bool // значение изменилось после последней инструкции
+}
+
+// Запись переменной в истории изменений
+type VarWrite struct {
+	Stmt  int // номер инструкции; 0 - изменение из отладчика
+	Pos   Span
+	Value Value // nil - переменная удалена
+}
+
+const varHistoryLimit = 100
+
+// значение наблюдаемого выражения; ошибка вычисления (например, переменная ещё не объявлена) - тоже значение
+func (interp *Interpreter) watchValue(expr string) string {
+	value, err := interp.evaluateText(expr)
+	var d *Diagnostic
+	if errors.As(err, &d) {
+		return "<" + d.Message + ">"
+	} else if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	return interp.DecimalToBase(value, 10)
+}
+
+// Добавление наблюдаемого выражения
+func (interp *Interpreter) AddWatch(expr string) (*Watch, error) {
+	expr = strings.TrimSpace(expr)
+	if _, err := interp.Parse("<наблюдение>", expr+";"); err != nil {
+		return nil, fmt.Errorf("ошибка в выражении %q: %v", expr, err)
+	}
+	w := &Watch{Expr: expr, Value: interp.watchValue(expr)}
+	interp.Watches = append(interp.Watches, w)
+	return w, nil
+}
+
+// Удаление по номеру из списка watches (с 1) или по тексту выражения
+func (interp *Interpreter) RemoveWatch(ref string) error {
+	ref = strings.TrimSpace(ref)
+	for i, w := range interp.Watches {
+		if w.Expr == ref || strconv.Itoa(i+1) == ref {
+			interp.Watches = append(interp.Watches[:i], interp.Watches[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("наблюдение %q не задано", ref)
+}
+
+// пересчёт наблюдаемых выражений; true - хотя бы одно изменилось
+func (interp *Interpreter) updateWatches() bool {
+	changed := false
+	for _, w := range interp.Watches {
+		value := interp.watchValue(w.Expr)
+		w.Changed = value != w.Value
+		if w.Changed {
+			w.Previous, w.Value = w.Value, value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// Изменившиеся наблюдаемые выражения в виде "выражение: было -> стало"
+func (interp *Interpreter) watchChanges() []string {
+	var changes []string
+	for _, w := range interp.Watches {
+		if w.Changed {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", w.Expr, w.Previous, w.Value))
+		}
+	}
+	return changes
+}
+
+// запись переменной; в режиме отладки запись попадает в историю изменений.
+// stmt == nil - изменение из отладчика
+func (interp *Interpreter) setVariable(name string, value Value, stmt Stmt) {
+	if value == nil {
+		interp.Variables.Delete(name)
+	} else {
+		interp.Variables.Insert(name, value)
+	}
+	if !interp.Debug {
+		return
+	}
+	write := VarWrite{Value: value}
+	if stmt != nil {
+		write.Stmt, write.Pos = stmt.Number(), stmt.Pos()
+	}
+	history, _ := interp.VarHistory.Search(name).([]VarWrite)
+	history = append(history, write)
+	if len(history) > varHistoryLimit {
+		history = history[len(history)-varHistoryLimit:]
+	}
+	interp.VarHistory.Insert(name, history)
+}
+
+// История изменений переменной, от старых записей к новой
+func (interp *Interpreter) VariableHistory(name string) []string {
+	history, _ := interp.VarHistory.Search(name).([]VarWrite)
+	var lines []string
+	for _, write := range history {
+		where := "отладчик"
+		if write.Stmt != 0 {
+			where = fmt.Sprintf("инструкция %d (%s)", write.Stmt, write.Pos)
+		}
+		value := "удалена"
+		if write.Value != nil {
+			value = interp.DecimalToBase(write.Value, 10)
+		}
+		lines = append(lines, where+": "+value)
+	}
+	return lines
+}
+
+// команды наблюдения: watch <выражение>, unwatch <номер|выражение>, watches, history <переменная>
+func (interp *Interpreter) watchCommand(name, args string) (output []string, handled bool, err error) {
+	switch name {
+	case "watch", "w":
+		w, err := interp.AddWatch(args)
+		if err != nil {
+			return nil, true, err
+		}
+		return []string{fmt.Sprintf("Наблюдение: %s = %s", w.Expr, w.Value)}, true, nil
+	case "unwatch":
+		return nil, true, interp.RemoveWatch(args)
+	case "watches":
+		for i, w := range interp.Watches {
+			output = append(output, fmt.Sprintf("%d) %s = %s", i+1, w.Expr, w.Value))
+		}
+		return output, true, nil
+	case "history":
+		name := strings.TrimSpace(args)
+		output = interp.VariableHistory(name)
+		if len(output) == 0 {
+			return nil, true, fmt.Errorf("нет записей в переменную %q", name)
+		}
+		return output, true, nil
+	}
+	return nil, false, nil
+}
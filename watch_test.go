@@ -0,0 +1,46 @@
+package interp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// наблюдаемые выражения не выводят, не вызывают функций и не прерывают программу
+func TestWatchHasNoSideEffects(t *testing.T) {
+	d := NewDialect()
+	d.Numeric = "big"
+	interp, err := New(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	interp.Out = &out
+	if err := interp.Execute("func f(a) {\n\toutput(a);\n\treturn a;\n}\nx = 3;\n"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	interp.Limits.MaxValueBits = 64
+	tests := []struct{ expr, want string }{
+		{"add(x, 1)", "4"},
+		{"pow(x, 100)", "значение больше 64 бит"},
+		{"f(x)", "вызов функции f недопустим"},
+		{"output(x)", "output недопустим"},
+		{"add(f(x), 1)", "вызов функции f недопустим"},
+	}
+	for _, test := range tests {
+		w, err := interp.AddWatch(test.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+		if !strings.Contains(w.Value, test.want) {
+			t.Errorf("%s = %q, ожидалось %q", test.expr, w.Value, test.want)
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("наблюдения вывели %q", out.String())
+	}
+	if interp.stopErr != nil {
+		t.Errorf("наблюдение прервало выполнение: %v", interp.stopErr)
+	}
+}